@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const defaultConfigFile = "glorm.json"
+
+// generatorConfig is loaded from the config file, e.g. glorm.json which is checked in alongside the models,
+// and the command line flags override it
+type generatorConfig struct {
+	Db string `json:"db"`
+	// DDL file of the CREATE TABLE statements, which is loaded instead of the database
+	DDL    string     `json:"ddl"`
+	Driver string     `json:"driver"`
+	Schema string     `json:"schema"`
+	Tables stringList `json:"tables"`
+	// Patterns of the tables to be excluded, e.g. _* for _yoyo_migrations
+	Exclude            stringList `json:"exclude"`
+	Pkg                string     `json:"pkg"`
+	Output             string     `json:"output"`
+	Template           string     `json:"template"`
+	DontTouchTimestamp bool       `json:"dont_touch_timestamp"`
+	EmbedTimestamps    bool       `json:"embed_timestamps"`
+	Nullable           string     `json:"nullable"`
+	JsonType           string     `json:"json_type"`
+	Decimal            string     `json:"decimal"`
+	// column type or data type -> Go type
+	Types map[string]string `json:"types"`
+	// table -> struct name, or table.column -> field name
+	Renames  map[string]string `json:"renames"`
+	Parallel int               `json:"parallel"`
+	// Directory of the migration files for glorm migrate
+	Migrations string `json:"migrations"`
+}
+
+func defaultGeneratorConfig() *generatorConfig {
+	return &generatorConfig{
+		Driver:     "mysql",
+		Exclude:    stringList{"_*"},
+		Nullable:   "pointer",
+		JsonType:   "json.RawMessage",
+		Decimal:    "float64",
+		Types:      map[string]string{},
+		Renames:    map[string]string{},
+		Parallel:   4,
+		Migrations: "migrations",
+	}
+}
+
+func (gc *generatorConfig) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&gc.Db, "db", gc.Db, "Target database source string: e.g. root@tcp(127.0.0.1:3306)/test?charset=utf-8")
+	fs.StringVar(&gc.DDL, "ddl", gc.DDL, "Generate from the CREATE TABLE statements of the sql file instead of the database, e.g. schema.sql")
+	fs.Var(&gc.Tables, "tables", "You may specify which tables the models need to be created, e.g. \"user,article,blog\"")
+	fs.Var(&gc.Exclude, "exclude", "Patterns of the tables to be excluded, e.g. \"_*,tmp_*\"")
+	fs.StringVar(&gc.Pkg, "pkg", gc.Pkg, "Go source code package for generated models")
+	fs.StringVar(&gc.Output, "output", gc.Output, "Output directory of generated models, the package name by default")
+	fs.StringVar(&gc.Driver, "driver", gc.Driver, "Current supported drivers include mysql, postgres")
+	fs.StringVar(&gc.Schema, "schema", gc.Schema, "Schema for postgresql, database name for mysql")
+	fs.BoolVar(&gc.DontTouchTimestamp, "dont-touch-timestamp", gc.DontTouchTimestamp, "Should touch the datetime fields with default value or on update")
+	fs.BoolVar(&gc.EmbedTimestamps, "embed-timestamps", gc.EmbedTimestamps, "Embed orm.Timestamps into the models instead of the auto updated created_at and updated_at fields")
+	fs.StringVar(&gc.Nullable, "nullable", gc.Nullable, "Go type of nullable columns, pointer(e.g. *string) or sql(e.g. sql.NullString)")
+	fs.StringVar(&gc.JsonType, "json-type", gc.JsonType, "Go type of json columns, e.g. map[string]interface{}, which is marshaled by the orm")
+	fs.StringVar(&gc.Decimal, "decimal", gc.Decimal, "Go type of decimal columns, e.g. string to keep the precision, or github.com/shopspring/decimal.Decimal with the import path")
+	fs.Var(&typeMapFile{types: gc.Types}, "types", "Json file of the type map overrides, e.g. {\"tinyint(1)\": \"int\", \"decimal\": \"string\"}")
+	fs.StringVar(&gc.Template, "template", gc.Template, "Passing the template to generate code, or use the default one")
+	fs.IntVar(&gc.Parallel, "p", gc.Parallel, "Parallell running for code generator")
+	fs.StringVar(&gc.Migrations, "migrations", gc.Migrations, "Directory of the migration files, e.g. 0001_create_user.up.sql and 0001_create_user.down.sql")
+}
+
+// Load the config file, and then override it by the flags set in command line.
+// The default config file is ignored if it doesn't exist
+func loadGeneratorConfig(fileName string, fs *flag.FlagSet) (*generatorConfig, error) {
+	gc := defaultGeneratorConfig()
+	data, err := ioutil.ReadFile(fileName)
+	if err == nil {
+		if err := json.Unmarshal(data, gc); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) || fileName != defaultConfigFile {
+		return nil, err
+	}
+	// the keys of the type map are case insensitive, which are lowercased before merging the -types file
+	types := make(map[string]string, len(gc.Types))
+	for k, v := range gc.Types {
+		types[strings.ToLower(k)] = v
+	}
+	gc.Types = types
+	if gc.Renames == nil {
+		gc.Renames = map[string]string{}
+	}
+	overrides := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	gc.bindFlags(overrides)
+	var setErr error
+	fs.Visit(func(f *flag.Flag) {
+		if overrides.Lookup(f.Name) != nil && setErr == nil {
+			setErr = overrides.Set(f.Name, f.Value.String())
+		}
+	})
+	if setErr != nil {
+		return nil, setErr
+	}
+	if gc.Output == "" {
+		gc.Output = gc.Pkg
+	}
+	return gc, nil
+}
+
+// The packages imported by the generated models, whose types don't need the import path
+var headerPackages = map[string]bool{"json": true, "sql": true, "orm": true, "time": true}
+
+// Split the Go type given with its import path into the qualified type and the import path, e.g.
+// github.com/shopspring/decimal.Decimal is decimal.Decimal of github.com/shopspring/decimal. The import path is
+// empty for the builtin types, the composite types like map[string]interface{} and the types of headerPackages
+func splitGoType(value string) (string, string, error) {
+	name := strings.TrimLeft(value, "*[]")
+	prefix := value[:len(value)-len(name)]
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 || strings.ContainsAny(name, "[]{} ") {
+		return value, "", nil
+	}
+	importPath, typeName := name[:dot], name[dot+1:]
+	pkg := importPath[strings.LastIndex(importPath, "/")+1:]
+	if !strings.Contains(importPath, "/") {
+		if !headerPackages[pkg] {
+			return "", "", fmt.Errorf("type %s should be given with its import path, e.g. github.com/shopspring/decimal.Decimal", value)
+		}
+		return value, "", nil
+	}
+	return prefix + pkg + "." + typeName, importPath, nil
+}
+
+// Resolve the Go types of the type map, decimal and json options into the qualified types,
+// returns the import paths of the qualified types
+func (gc *generatorConfig) resolveTypes() (map[string]string, error) {
+	imports := map[string]string{}
+	resolve := func(value string) (string, error) {
+		goType, importPath, err := splitGoType(value)
+		if err == nil && importPath != "" {
+			imports[strings.TrimLeft(goType, "*[]")] = importPath
+		}
+		return goType, err
+	}
+	var err error
+	for k, v := range gc.Types {
+		if gc.Types[k], err = resolve(v); err != nil {
+			return nil, err
+		}
+	}
+	if gc.Decimal, err = resolve(gc.Decimal); err != nil {
+		return nil, err
+	}
+	if gc.JsonType, err = resolve(gc.JsonType); err != nil {
+		return nil, err
+	}
+	return imports, nil
+}
+
+// stringList is the flag of comma separated values, e.g. "user,article,blog"
+type stringList []string
+
+func (sl *stringList) String() string {
+	if sl == nil {
+		return ""
+	}
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = stringList{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*sl = append(*sl, v)
+		}
+	}
+	return nil
+}
+
+// typeMapFile is the flag of json file, which is merged into the type map with the lowercased keys
+type typeMapFile struct {
+	types    map[string]string
+	fileName string
+}
+
+func (tf *typeMapFile) String() string {
+	if tf == nil {
+		return ""
+	}
+	return tf.fileName
+}
+
+func (tf *typeMapFile) Set(fileName string) error {
+	tf.fileName = fileName
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	types := map[string]string{}
+	if err := json.Unmarshal(data, &types); err != nil {
+		return err
+	}
+	for k, v := range types {
+		tf.types[strings.ToLower(k)] = v
+	}
+	return nil
+}
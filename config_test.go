@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Parse the args by the flags of the generator, and then load the config file
+func loadTestConfig(fileName string, args ...string) (*generatorConfig, error) {
+	fs := flag.NewFlagSet("glorm", flag.ContinueOnError)
+	defaultGeneratorConfig().bindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return loadGeneratorConfig(fileName, fs)
+}
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestLoadGeneratorConfig(t *testing.T) {
+	configFile := writeTestFile(t, "glorm.json", `{
+		"db": "root@tcp(127.0.0.1:3306)/blog",
+		"pkg": "models",
+		"tables": ["user", "article"],
+		"nullable": "sql",
+		"types": {"TINYINT(1)": "int", "decimal": "string"},
+		"renames": {"user": "Account"}
+	}`)
+	gc, err := loadTestConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc.Db != "root@tcp(127.0.0.1:3306)/blog" || gc.Pkg != "models" || gc.Output != "models" || gc.Nullable != "sql" {
+		t.Fatal("incorrect config", gc)
+	}
+	if !reflect.DeepEqual(gc.Tables, stringList{"user", "article"}) || gc.Renames["user"] != "Account" {
+		t.Fatal("incorrect tables or renames", gc.Tables, gc.Renames)
+	}
+	// the defaults are kept if they are not in the file
+	if gc.Driver != "mysql" || gc.Decimal != "float64" || gc.Parallel != 4 || !reflect.DeepEqual(gc.Exclude, stringList{"_*"}) {
+		t.Fatal("incorrect defaults", gc)
+	}
+	if !reflect.DeepEqual(gc.Types, map[string]string{"tinyint(1)": "int", "decimal": "string"}) {
+		t.Fatal("incorrect types", gc.Types)
+	}
+
+	// the flags set in command line override the file
+	gc, err = loadTestConfig(configFile, "-pkg", "dao", "-tables", "blog", "-nullable", "pointer", "-output", "gen/dao")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc.Pkg != "dao" || gc.Output != "gen/dao" || gc.Nullable != "pointer" || !reflect.DeepEqual(gc.Tables, stringList{"blog"}) {
+		t.Fatal("the flags should override the file", gc)
+	}
+	if gc.Db != "root@tcp(127.0.0.1:3306)/blog" {
+		t.Fatal("the unset flags should not override the file", gc.Db)
+	}
+
+	// the types file is merged into the types of the config file, and the keys are case insensitive
+	typesFile := writeTestFile(t, "types.json", `{"DECIMAL": "float64", "JSON": "map[string]interface{}"}`)
+	gc, err = loadTestConfig(configFile, "-types", typesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"tinyint(1)": "int", "decimal": "float64", "json": "map[string]interface{}"}
+	if !reflect.DeepEqual(gc.Types, expected) {
+		t.Fatal("incorrect merged types", gc.Types)
+	}
+
+	if _, err := loadTestConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("the missing config file should be an error")
+	}
+	if _, err := loadTestConfig(writeTestFile(t, "bad.json", `{"db": `)); err == nil {
+		t.Fatal("the invalid config file should be an error")
+	}
+	if _, err := loadTestConfig(configFile, "-types", filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("the missing types file should be an error")
+	}
+}
+
+func TestTypeMapFile(t *testing.T) {
+	types := map[string]string{"tinyint(1)": "int", "json": "json.RawMessage"}
+	tf := &typeMapFile{types: types}
+	if err := tf.Set(writeTestFile(t, "types.json", `{"json": "map[string]interface{}", "decimal": "string"}`)); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"tinyint(1)": "int", "json": "map[string]interface{}", "decimal": "string"}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatal("incorrect merged types", types)
+	}
+	if err := tf.Set(writeTestFile(t, "upper.json", `{"TINYINT(1)": "bool"}`)); err != nil || types["tinyint(1)"] != "bool" || len(types) != 3 {
+		t.Fatal("the keys should be lowercased", types, err)
+	}
+	if err := tf.Set(writeTestFile(t, "bad.json", `["json"]`)); err == nil {
+		t.Fatal("the invalid types file should be an error")
+	}
+}
+
+func TestSplitGoType(t *testing.T) {
+	cases := []struct {
+		value      string
+		goType     string
+		importPath string
+	}{
+		{"int64", "int64", ""},
+		{"map[string]interface{}", "map[string]interface{}", ""},
+		{"json.RawMessage", "json.RawMessage", ""},
+		{"*time.Time", "*time.Time", ""},
+		{"github.com/shopspring/decimal.Decimal", "decimal.Decimal", "github.com/shopspring/decimal"},
+		{"*github.com/google/uuid.UUID", "*uuid.UUID", "github.com/google/uuid"},
+		{"[]example.com/pkg/types.Tag", "[]types.Tag", "example.com/pkg/types"},
+	}
+	for _, c := range cases {
+		goType, importPath, err := splitGoType(c.value)
+		if err != nil || goType != c.goType || importPath != c.importPath {
+			t.Errorf("%s: expected %s of %q, but got %s of %q, %v", c.value, c.goType, c.importPath, goType, importPath, err)
+		}
+	}
+	for _, value := range []string{"decimal.Decimal", "*uuid.UUID"} {
+		if _, _, err := splitGoType(value); err == nil {
+			t.Errorf("%s: expected error", value)
+		}
+	}
+}
+
+func TestResolveTypes(t *testing.T) {
+	gc := &generatorConfig{
+		Types:   map[string]string{"char(36)": "github.com/google/uuid.UUID", "tinyint": "bool"},
+		Decimal: "*github.com/shopspring/decimal.Decimal",
+	}
+	imports, err := gc.resolveTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gc.Types, map[string]string{"char(36)": "uuid.UUID", "tinyint": "bool"}) || gc.Decimal != "*decimal.Decimal" {
+		t.Fatal("incorrect types", gc.Types, gc.Decimal)
+	}
+	expected := map[string]string{"uuid.UUID": "github.com/google/uuid", "decimal.Decimal": "github.com/shopspring/decimal"}
+	if !reflect.DeepEqual(imports, expected) {
+		t.Fatal("incorrect imports", imports)
+	}
+
+	gc = &generatorConfig{JsonType: "tags.Tags"}
+	if _, err := gc.resolveTypes(); err == nil {
+		t.Fatal("the qualified type without import path should be rejected")
+	}
+}
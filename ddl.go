@@ -0,0 +1,471 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ddlToken is a token of the DDL, the quoted identifier or string is unquoted with quoted set
+type ddlToken struct {
+	text   string
+	quoted bool
+}
+
+// Whether the token is one of the keywords or symbols, case insensitive
+func (t ddlToken) is(words ...string) bool {
+	if t.quoted {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load the table schemas from the CREATE TABLE statements of the DDL file, e.g. the output of mysqldump --no-data,
+// so that the models can be generated without a database. The other statements are ignored
+func loadDDLSchema(fileName, schema, tableNames string) (DbSchema, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return parseDDL(string(data), schema, tableNames)
+}
+
+func parseDDL(ddl, schema, tableNames string) (DbSchema, error) {
+	tokens, err := tokenizeDDL(ddl)
+	if err != nil {
+		return nil, err
+	}
+	tables := map[string]bool{}
+	for _, table := range strings.Split(tableNames, ",") {
+		if table != "" {
+			tables[table] = true
+		}
+	}
+	dbSchema := DbSchema{}
+	for _, stmt := range splitTokens(tokens, ";") {
+		if len(stmt) == 0 || !stmt[0].is("CREATE") {
+			continue
+		}
+		p := &ddlParser{tokens: stmt[1:]}
+		p.accept("TEMPORARY")
+		if !p.accept("TABLE") {
+			continue
+		}
+		name, cols, err := p.createTable(schema)
+		if err != nil {
+			return nil, err
+		}
+		if len(tables) == 0 || tables[name] {
+			dbSchema[name] = cols
+		}
+	}
+	return dbSchema, nil
+}
+
+// Split the DDL into tokens, the comments are skipped
+func tokenizeDDL(ddl string) ([]ddlToken, error) {
+	tokens := make([]ddlToken, 0, len(ddl)/4)
+	for i := 0; i < len(ddl); {
+		ch := ddl[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '#' || strings.HasPrefix(ddl[i:], "--") && (i+2 == len(ddl) || ddl[i+2] <= ' '):
+			for i < len(ddl) && ddl[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(ddl[i:], "/*"):
+			end := strings.Index(ddl[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at %d", i)
+			}
+			i += end + 4
+		case ch == '`' || ch == '\'' || ch == '"':
+			text, n, err := unquoteDDL(ddl[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, ddlToken{text: text, quoted: true})
+			i += n
+		case isWordChar(ch):
+			start := i
+			// the number could be decimal, e.g. 1.5
+			for i < len(ddl) && (isWordChar(ddl[i]) || ddl[i] == '.' && ch >= '0' && ch <= '9') {
+				i++
+			}
+			// bit or hex literal, e.g. b'0' and x'ff'
+			if i-start == 1 && i < len(ddl) && ddl[i] == '\'' && strings.ContainsRune("bBxX", rune(ch)) {
+				text, n, err := unquoteDDL(ddl[i:])
+				if err != nil {
+					return nil, err
+				}
+				i += n
+				tokens = append(tokens, ddlToken{text: strings.ToLower(ddl[start:start+1]) + "'" + text + "'"})
+				continue
+			}
+			tokens = append(tokens, ddlToken{text: ddl[start:i]})
+		default:
+			tokens = append(tokens, ddlToken{text: ddl[i : i+1]})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || ch == '$' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// Unquote the identifier or string at the beginning of s, returns the text and the length of the quoted one
+func unquoteDDL(s string) (string, int, error) {
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == quote && i+1 < len(s) && s[i+1] == quote:
+			sb.WriteByte(quote)
+			i++
+		case ch == quote:
+			return sb.String(), i + 1, nil
+		case ch == '\\' && quote != '`' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '0':
+				sb.WriteByte(0)
+			default:
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quote %c", quote)
+}
+
+// Split the tokens by the separator out of parentheses
+func splitTokens(tokens []ddlToken, sep string) [][]ddlToken {
+	ret := make([][]ddlToken, 0)
+	depth, start := 0, 0
+	for i, t := range tokens {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case t.is(sep) && depth == 0:
+			ret = append(ret, tokens[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		ret = append(ret, tokens[start:])
+	}
+	return ret
+}
+
+type ddlParser struct {
+	tokens []ddlToken
+	pos    int
+}
+
+func (p *ddlParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *ddlParser) peek() ddlToken {
+	if p.done() {
+		return ddlToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *ddlParser) next() ddlToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// Consume the next tokens if they are the words
+func (p *ddlParser) accept(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	for i, w := range words {
+		if !p.tokens[p.pos+i].is(w) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+// The tokens in the parentheses, which is consumed with the parentheses
+func (p *ddlParser) group() ([]ddlToken, error) {
+	if !p.accept("(") {
+		return nil, fmt.Errorf("expected ( but got %q", p.peek().text)
+	}
+	start, depth := p.pos, 1
+	for ; !p.done(); p.pos++ {
+		if p.peek().is("(") {
+			depth++
+		} else if p.peek().is(")") {
+			if depth--; depth == 0 {
+				p.pos++
+				return p.tokens[start : p.pos-1], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unbalanced parentheses")
+}
+
+// The text of the tokens, i.e. the default expression, strings are quoted as information_schema shows
+func ddlText(tokens []ddlToken) string {
+	var sb strings.Builder
+	for i, t := range tokens {
+		if i > 0 && !t.is("(", ")", ",") && !tokens[i-1].is("(") {
+			sb.WriteByte(' ')
+		}
+		if t.quoted {
+			sb.WriteString("'" + strings.Replace(t.text, "'", "''", -1) + "'")
+		} else {
+			sb.WriteString(t.text)
+		}
+	}
+	return sb.String()
+}
+
+// Parse CREATE TABLE [IF NOT EXISTS] name (definitions) options, the CREATE TABLE has been consumed
+func (p *ddlParser) createTable(schema string) (string, TableSchema, error) {
+	p.accept("IF", "NOT", "EXISTS")
+	name := p.next().text
+	// database qualified name, e.g. `blog`.`article`
+	if p.accept(".") {
+		name = p.next().text
+	}
+	if name == "" || p.peek().is("LIKE") {
+		return "", nil, fmt.Errorf("unsupported create table %s", name)
+	}
+	defs, err := p.group()
+	if err != nil {
+		return "", nil, fmt.Errorf("table %s: %w", name, err)
+	}
+	cols := make(TableSchema, 0)
+	byName := map[string]int{}
+	// key of the index columns, PRI, UNI or MUL
+	keys := map[string]string{}
+	setKey := func(col string, key string) {
+		// PRI goes before UNI and MUL
+		if old := keys[col]; old == "" || old == "MUL" || key == "PRI" {
+			keys[col] = key
+		}
+	}
+	for _, def := range splitTokens(defs, ",") {
+		dp := &ddlParser{tokens: def}
+		if dp.isIndex() {
+			key, indexCols, err := dp.index()
+			if err != nil {
+				return "", nil, fmt.Errorf("table %s: %w", name, err)
+			}
+			switch {
+			case key == "PRI":
+				for _, c := range indexCols {
+					setKey(c, key)
+				}
+			case key == "UNI" && len(indexCols) == 1:
+				setKey(indexCols[0], key)
+			case key != "" && len(indexCols) > 0:
+				setKey(indexCols[0], "MUL")
+			}
+			continue
+		}
+		col, err := dp.column()
+		if err != nil {
+			return "", nil, fmt.Errorf("table %s: %w", name, err)
+		}
+		col.TableSchema = schema
+		col.TableName = name
+		if col.ColumnKey != "" {
+			setKey(col.ColumnName, col.ColumnKey)
+		}
+		byName[col.ColumnName] = len(cols)
+		cols = append(cols, col)
+	}
+	for c, key := range keys {
+		i, ok := byName[c]
+		if !ok {
+			return "", nil, fmt.Errorf("table %s: key on unknown column %s", name, c)
+		}
+		cols[i].ColumnKey = key
+		if key == "PRI" {
+			cols[i].IsNullable = "NO"
+		}
+	}
+	return name, cols, nil
+}
+
+// Whether the definition is an index or constraint rather than a column
+func (p *ddlParser) isIndex() bool {
+	return p.peek().is("PRIMARY", "UNIQUE", "KEY", "INDEX", "FULLTEXT", "SPATIAL", "CONSTRAINT", "FOREIGN", "CHECK")
+}
+
+// Parse the index definition, returns the key of the index and its columns, the key is empty if it isn't an index
+func (p *ddlParser) index() (string, []string, error) {
+	if p.accept("CONSTRAINT") && !p.peek().is("PRIMARY", "UNIQUE", "FOREIGN", "CHECK") {
+		p.next()
+	}
+	key := "MUL"
+	switch {
+	case p.accept("CHECK"):
+		return "", nil, nil
+	case p.accept("PRIMARY", "KEY"):
+		key = "PRI"
+	case p.accept("UNIQUE"):
+		key = "UNI"
+		if !p.accept("KEY") {
+			p.accept("INDEX")
+		}
+	default:
+		p.accept("FULLTEXT")
+		p.accept("SPATIAL")
+		p.accept("FOREIGN")
+		if !p.accept("KEY") {
+			p.accept("INDEX")
+		}
+	}
+	// optional index name and type
+	for !p.done() && !p.peek().is("(") {
+		p.next()
+	}
+	group, err := p.group()
+	if err != nil {
+		return "", nil, err
+	}
+	cols := make([]string, 0)
+	for _, part := range splitTokens(group, ",") {
+		// the functional key part has no column, e.g. ((lower(name)))
+		if len(part) > 0 && !part[0].is("(") {
+			cols = append(cols, part[0].text)
+		}
+	}
+	return key, cols, nil
+}
+
+// Parse the column definition: name type [attributes]
+func (p *ddlParser) column() (column, error) {
+	col := column{ColumnName: p.next().text, IsNullable: "YES"}
+	if col.ColumnName == "" || p.done() {
+		return col, fmt.Errorf("invalid column definition %s", ddlText(p.tokens))
+	}
+	col.DataType = strings.ToLower(p.next().text)
+	switch col.DataType {
+	case "integer":
+		col.DataType = "int"
+	case "bool", "boolean":
+		col.DataType, col.ColumnType = "tinyint", "tinyint(1)"
+	case "dec", "fixed":
+		col.DataType = "decimal"
+	case "double":
+		p.accept("PRECISION")
+	}
+	if col.ColumnType == "" {
+		col.ColumnType = col.DataType
+	}
+	if p.peek().is("(") {
+		args, err := p.group()
+		if err != nil {
+			return col, fmt.Errorf("column %s: %w", col.ColumnName, err)
+		}
+		col.ColumnType += "(" + strings.Replace(ddlText(args), ", ", ",", -1) + ")"
+	}
+	for p.peek().is("UNSIGNED", "ZEROFILL", "SIGNED") {
+		if t := p.next(); !t.is("SIGNED") {
+			col.ColumnType += " " + strings.ToLower(t.text)
+		}
+	}
+	extras := make([]string, 0, 1)
+	for !p.done() {
+		switch {
+		case p.accept("NOT", "NULL"):
+			col.IsNullable = "NO"
+		case p.accept("NULL"):
+			col.IsNullable = "YES"
+		case p.accept("DEFAULT"):
+			col.ColumnDefault = p.defaultValue()
+		case p.accept("AUTO_INCREMENT"):
+			extras = append(extras, "auto_increment")
+		case p.accept("ON", "UPDATE"):
+			extras = append(extras, "on update "+p.defaultValue().String)
+		case p.accept("PRIMARY", "KEY"), p.accept("KEY"):
+			col.ColumnKey = "PRI"
+		case p.accept("UNIQUE"):
+			p.accept("KEY")
+			col.ColumnKey = "UNI"
+		case p.accept("COMMENT"):
+			col.ColumnComment = p.next().text
+		case p.accept("CHARACTER", "SET"), p.accept("CHARSET"), p.accept("COLLATE"):
+			p.next()
+		case p.accept("GENERATED", "ALWAYS"), p.accept("AS"):
+			p.accept("AS")
+			if _, err := p.group(); err != nil {
+				return col, fmt.Errorf("column %s: %w", col.ColumnName, err)
+			}
+			if p.accept("STORED") {
+				extras = append(extras, "STORED GENERATED")
+			} else {
+				p.accept("VIRTUAL")
+				extras = append(extras, "VIRTUAL GENERATED")
+			}
+		case p.accept("REFERENCES"):
+			p.pos = len(p.tokens)
+		case p.peek().is("("):
+			// i.e. CHECK (expr)
+			if _, err := p.group(); err != nil {
+				return col, fmt.Errorf("column %s: %w", col.ColumnName, err)
+			}
+		default:
+			p.next()
+		}
+	}
+	col.Extra = strings.Join(extras, " ")
+	return col, nil
+}
+
+// Parse the default value, NULL is invalid, the string is unquoted and CURRENT_TIMESTAMP is normalized as
+// information_schema shows
+func (p *ddlParser) defaultValue() sql.NullString {
+	t := p.next()
+	switch {
+	case t.quoted:
+		return sql.NullString{String: t.text, Valid: true}
+	case t.is("NULL"):
+		return sql.NullString{}
+	case t.is("-", "+"):
+		return sql.NullString{String: strings.TrimPrefix(t.text, "+") + p.next().text, Valid: true}
+	case t.is("("):
+		p.pos--
+		expr, _ := p.group()
+		return sql.NullString{String: ddlText(expr), Valid: true}
+	case t.is("CURRENT_TIMESTAMP", "NOW", "LOCALTIME", "LOCALTIMESTAMP"):
+		value := "CURRENT_TIMESTAMP"
+		if p.peek().is("(") {
+			if args, _ := p.group(); len(args) > 0 {
+				value += "(" + ddlText(args) + ")"
+			}
+		}
+		return sql.NullString{String: value, Valid: true}
+	}
+	return sql.NullString{String: t.text, Valid: true}
+}
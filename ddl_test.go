@@ -0,0 +1,166 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The texts of the tokens, the quoted ones are quoted by Go syntax
+func tokenTexts(tokens []ddlToken) []string {
+	ret := make([]string, len(tokens))
+	for i, t := range tokens {
+		if t.quoted {
+			ret[i] = strconv.Quote(t.text)
+		} else {
+			ret[i] = t.text
+		}
+	}
+	return ret
+}
+
+func TestTokenizeDDL(t *testing.T) {
+	cases := []struct {
+		ddl    string
+		tokens []string
+	}{
+		{"CREATE TABLE `a``b` (`c` int)", []string{"CREATE", "TABLE", `"a` + "`" + `b"`, "(", `"c"`, "int", ")"}},
+		{`DEFAULT 'it''s' "a\"b" 'x\ny' 'c:\\d'`, []string{"DEFAULT", `"it's"`, `"a\"b"`, `"x\ny"`, `"c:\\d"`}},
+		{"/*!40101 SET NAMES utf8 */;\n-- comment\nSELECT 1 # tail\n/* multi\nline */", []string{";", "SELECT", "1"}},
+		{"a --1", []string{"a", "-", "-", "1"}},
+		{"DEFAULT -1.5 b'01' X'FF' `blog`.`t`", []string{"DEFAULT", "-", "1.5", "b'01'", "x'FF'", `"blog"`, ".", `"t"`}},
+		{"", []string{}},
+	}
+	for _, c := range cases {
+		tokens, err := tokenizeDDL(c.ddl)
+		if err != nil {
+			t.Errorf("%q: %v", c.ddl, err)
+			continue
+		}
+		if texts := tokenTexts(tokens); !reflect.DeepEqual(texts, c.tokens) {
+			t.Errorf("%q: expected %q, but got %q", c.ddl, c.tokens, texts)
+		}
+	}
+	for _, ddl := range []string{"/* open", "DEFAULT 'open", "`open", "x'ff"} {
+		if _, err := tokenizeDDL(ddl); err == nil {
+			t.Errorf("%q: expected error", ddl)
+		}
+	}
+}
+
+// The summary of the column: name|column type|data type|nullable|key|default|extra, the default is NULL if invalid
+func columnSummary(col column) string {
+	dflt := "NULL"
+	if col.ColumnDefault.Valid {
+		dflt = col.ColumnDefault.String
+	}
+	return strings.Join([]string{col.ColumnName, col.ColumnType, col.DataType, col.IsNullable, col.ColumnKey, dflt, col.Extra}, "|")
+}
+
+func TestParseDDL(t *testing.T) {
+	cases := []struct {
+		name   string
+		ddl    string
+		tables string
+		schema map[string][]string
+	}{
+		{
+			name: "quoted identifiers and column attributes",
+			ddl: "CREATE TABLE IF NOT EXISTS `blog`.`article` (\n" +
+				"  `article_id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'the id',\n" +
+				"  `it``s` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT 'it''s',\n" +
+				"  state enum('a', 'b') DEFAULT 'a',\n" +
+				"  price decimal(20, 4) DEFAULT -1.5,\n" +
+				"  flag boolean NOT NULL DEFAULT b'0',\n" +
+				"  total int GENERATED ALWAYS AS (price * 2) STORED,\n" +
+				"  updated_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),\n" +
+				"  deleted_at datetime NULL DEFAULT NULL,\n" +
+				"  PRIMARY KEY (`article_id`)\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='articles'",
+			schema: map[string][]string{
+				"article": {
+					"article_id|bigint(20) unsigned|bigint|NO|PRI|NULL|auto_increment",
+					"it`s|varchar(255)|varchar|NO||it's|",
+					"state|enum('a','b')|enum|YES||a|",
+					"price|decimal(20,4)|decimal|YES||-1.5|",
+					"flag|tinyint(1)|tinyint|NO||b'0'|",
+					"total|int|int|YES||NULL|STORED GENERATED",
+					"updated_at|datetime(3)|datetime|NO||CURRENT_TIMESTAMP(3)|on update CURRENT_TIMESTAMP(3)",
+					"deleted_at|datetime|datetime|YES||NULL|",
+				},
+			},
+		},
+		{
+			name: "indexes and constraints",
+			ddl: `CREATE TABLE article_tag (
+				  article_id bigint,
+				  tag varchar(20) NOT NULL,
+				  slug varchar(64) NOT NULL,
+				  author_id int,
+				  title varchar(20),
+				  PRIMARY KEY (article_id, tag),
+				  UNIQUE KEY uk_slug (slug),
+				  UNIQUE KEY uk_multi (author_id, title(10)),
+				  KEY idx_title USING BTREE (title),
+				  CONSTRAINT fk_author FOREIGN KEY (author_id) REFERENCES user (id) ON DELETE CASCADE,
+				  CHECK (article_id > 0)
+				)`,
+			schema: map[string][]string{
+				"article_tag": {
+					"article_id|bigint|bigint|NO|PRI|NULL|",
+					"tag|varchar(20)|varchar|NO|PRI|NULL|",
+					"slug|varchar(64)|varchar|NO|UNI|NULL|",
+					"author_id|int|int|YES|MUL|NULL|",
+					"title|varchar(20)|varchar|YES|MUL|NULL|",
+				},
+			},
+		},
+		{
+			name: "other statements are skipped",
+			ddl: "-- MySQL dump\n/*!40101 SET NAMES utf8 */;\nDROP TABLE IF EXISTS `a`;\n" +
+				"CREATE TABLE a (id integer key);\nINSERT INTO a VALUES (1, 'x;y');\n" +
+				"CREATE VIEW v AS SELECT 1;\nCREATE TEMPORARY TABLE b (id int UNIQUE)",
+			schema: map[string][]string{
+				"a": {"id|int|int|NO|PRI|NULL|"},
+				"b": {"id|int|int|YES|UNI|NULL|"},
+			},
+		},
+		{
+			name:   "tables are filtered",
+			ddl:    "CREATE TABLE a (id int); CREATE TABLE b (id int)",
+			tables: "b",
+			schema: map[string][]string{"b": {"id|int|int|YES||NULL|"}},
+		},
+	}
+	for _, c := range cases {
+		schema, err := parseDDL(c.ddl, "blog", c.tables)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		summary := make(map[string][]string, len(schema))
+		for table, cols := range schema {
+			for _, col := range cols {
+				if col.TableSchema != "blog" || col.TableName != table {
+					t.Errorf("%s: unexpected table of column %v", c.name, col)
+				}
+				summary[table] = append(summary[table], columnSummary(col))
+			}
+		}
+		if !reflect.DeepEqual(summary, c.schema) {
+			t.Errorf("%s: expected %q, but got %q", c.name, c.schema, summary)
+		}
+	}
+
+	for _, ddl := range []string{
+		"CREATE TABLE t (id int",
+		"CREATE TABLE t (a int, KEY (b))",
+		"CREATE TABLE t LIKE x",
+		"CREATE TABLE t (a varchar(1) DEFAULT 'x)",
+	} {
+		if _, err := parseDDL(ddl, "blog", ""); err == nil {
+			t.Errorf("%q: expected error", ddl)
+		}
+	}
+}
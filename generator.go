@@ -2,12 +2,16 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"go/token"
 	"log"
 	"os"
 	"path"
+	"sort"
 	"strings"
 	"text/template"
+	"unicode"
 )
 
 type CodeResult struct {
@@ -16,10 +20,41 @@ type CodeResult struct {
 }
 
 type codeConfig struct {
-	packageName    string
-	touchTimestamp bool
-	template       string
-	dbString       string
+	packageName     string
+	touchTimestamp  bool
+	embedTimestamps bool
+	nullStyle       string
+	jsonType        string
+	decimalType     string
+	template        string
+	dbString        string
+	outputDir       string
+	// column type or data type -> Go type, which overrides the builtin mapping
+	typeMap map[string]string
+	// qualified Go type of the options -> import path, e.g. decimal.Decimal -> github.com/shopspring/decimal
+	imports map[string]string
+	// table -> struct name, or table.column -> field name
+	renames map[string]string
+	// patterns of the excluded tables
+	exclude []string
+}
+
+// The renamed struct or field name of the table or table.column, or the converted name by default
+func (cc codeConfig) rename(name string, converted string) string {
+	if renamed := cc.renames[name]; renamed != "" {
+		return renamed
+	}
+	return converted
+}
+
+// Whether the table matches any of the exclude patterns
+func (cc codeConfig) excluded(table string) bool {
+	for _, pattern := range cc.exclude {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true
+		}
+	}
+	return false
 }
 
 func (cc codeConfig) MustCompileTemplate() *template.Template {
@@ -32,8 +67,8 @@ func (cc codeConfig) MustCompileTemplate() *template.Template {
 func generateModels(dbName string, dbSchema DbSchema, config codeConfig) {
 	customTmpl := config.MustCompileTemplate()
 
-	if fs, err := os.Stat(config.packageName); err != nil || !fs.IsDir() {
-		os.Mkdir(config.packageName, os.ModeDir|os.ModePerm)
+	if fs, err := os.Stat(config.outputDir); err != nil || !fs.IsDir() {
+		os.MkdirAll(config.outputDir, os.ModeDir|os.ModePerm)
 	}
 
 	jobs := make(chan CodeResult)
@@ -49,18 +84,18 @@ func generateModels(dbName string, dbSchema DbSchema, config codeConfig) {
 		if result.err != nil {
 			log.Printf("Error when generating code for %s, %s", result.name, result.err)
 		} else {
-			log.Printf("Code generated for table %s, into package %s/%s.go", result.name, config.packageName, result.name)
+			log.Printf("Code generated for table %s, into package %s/%s.go", result.name, config.outputDir, result.name)
 		}
 	}
 	close(jobs)
 }
 
 func generateModel(dbName, tName string, schema TableSchema, config codeConfig, tmpl *template.Template) error {
-	// omit table with "_" as prefix, such as _yoyo_migrations
-	if strings.HasPrefix(tName, "_") {
+	// omit the excluded table, such as _glorm_migrations
+	if config.excluded(tName) {
 		return nil
 	}
-	file, err := os.Create(path.Join(config.packageName, tName+".go"))
+	file, err := os.Create(path.Join(config.outputDir, tName+".go"))
 	if err != nil {
 		return err
 	}
@@ -71,23 +106,26 @@ func generateModel(dbName, tName string, schema TableSchema, config codeConfig,
 		file.Close()
 	}()
 
+	modelName := config.rename(tName, toCapitalCase(tName, true))
 	model := ModelMeta{
-		Name:      toCapitalCase(tName, true),
-		LowerName: toCapitalCase(tName, false),
-		DbName:    dbName,
-		TableName: tName,
-		DbString:  config.dbString,
-		Fields:    make([]ModelField, len(schema)),
-		Uniques:   make([]ModelField, 0, len(schema)),
-		config:    config,
+		Name:          modelName,
+		LowerName:     strings.ToLower(modelName[:1]) + modelName[1:],
+		DbName:        dbName,
+		TableName:     tName,
+		DbString:      config.dbString,
+		Fields:        make([]ModelField, len(schema)),
+		Uniques:       make([]ModelField, 0, len(schema)),
+		PrimaryFields: make(PrimaryFields, 0, 1),
+		config:        config,
 	}
 	needTime := false
 	needTimeForTest := false
+	imports := map[string]bool{}
 	for i, col := range schema {
 		field := ModelField{
-			Name:            toCapitalCase(col.ColumnName, true),
+			Name:            config.rename(tName+"."+col.ColumnName, toCapitalCase(col.ColumnName, true)),
 			ColumnName:      col.ColumnName,
-			Type:            col.GetDataType(),
+			Type:            col.GetDataType(config),
 			Tag:             "",
 			IsPrimaryKey:    strings.ToUpper(col.ColumnKey) == "PRI",
 			IsUniqueKey:     strings.ToUpper(col.ColumnKey) == "UNI",
@@ -97,12 +135,31 @@ func generateModel(dbName, tName string, schema TableSchema, config codeConfig,
 			Comment:         col.ColumnComment,
 		}
 
+		tags := make([]string, 0, 3)
+		if toUnderscoreCase(field.Name) != col.ColumnName {
+			// the orm maps the field to column by convention, which doesn't work for this column
+			tags = append(tags, fmt.Sprintf("db:\"%s\"", col.ColumnName))
+		}
 		if (col.ColumnName == "created_at" || col.ColumnName == "updated_at") && col.ColumnDefault.Valid {
-			field.Tag = fmt.Sprintf("`ignore:\"true\"`")
+			tags = append(tags, "ignore:\"true\"")
 			field.IgnoreOnInsert = true
 		}
 
-		if field.Type == "time.Time" {
+		if strings.ToLower(col.DataType) == "json" {
+			// json is NULL if it's nil, which is marshaled by the orm if it's not json.RawMessage
+			if field.Type == "json.RawMessage" && config.jsonType != "" {
+				field.Type = config.jsonType
+			}
+			if field.Type != "json.RawMessage" {
+				tags = append(tags, "conv:\"json\"")
+			}
+		} else if col.Nullable() && !field.IsPrimaryKey {
+			// NULL is scanned into nil pointer or invalid sql.Null*, which is also the zero value to insert
+			field.Type = nullableType(field.Type, config.nullStyle)
+			if field.Type == "*time.Time" {
+				needTime = true
+			}
+		} else if field.Type == "time.Time" {
 			needTime = true
 			if !field.IgnoreOnInsert {
 				needTimeForTest = true
@@ -114,17 +171,27 @@ func generateModel(dbName, tName string, schema TableSchema, config codeConfig,
 		} else if field.Type == "string" {
 			field.DefaultValueCode = "\"\""
 		}
+		if importPath := config.imports[strings.TrimLeft(field.Type, "*[]")]; importPath != "" {
+			imports[importPath] = true
+		} else if strings.Contains(field.Type, "time.") {
+			// the type of the type map, e.g. time.Duration
+			needTime = true
+		}
 		if field.IsPrimaryKey {
-			if model.PrimaryField != nil {
-				return fmt.Errorf("must not have more than one primary keys, %+v", field)
+			// PrimaryField is the first one of the primary keys, composite keys are all in PrimaryFields
+			if model.PrimaryField == nil {
+				model.PrimaryField = &field
 			}
-			model.PrimaryField = &field
+			model.PrimaryFields = append(model.PrimaryFields, &field)
 			if field.IsAutoIncrement {
-				field.Tag = fmt.Sprintf("`pk:\"true\" ai:\"true\"`")
+				tags = append(tags, "pk:\"true\" ai:\"true\"")
 			} else {
-				field.Tag = fmt.Sprintf("`pk:\"true\"`")
+				tags = append(tags, "pk:\"true\"")
 			}
 		}
+		if len(tags) > 0 {
+			field.Tag = "`" + strings.Join(tags, " ") + "`"
+		}
 
 		if field.IsUniqueKey {
 			model.Uniques = append(model.Uniques, field)
@@ -132,6 +199,18 @@ func generateModel(dbName, tName string, schema TableSchema, config codeConfig,
 
 		model.Fields[i] = field
 	}
+	if config.embedTimestamps && model.embedTimestamps() {
+		// the time package may be only used by the embedded fields
+		needTime = false
+		for _, f := range model.Fields {
+			needTime = needTime || (!f.InEmbed && strings.Contains(f.Type, "time."))
+		}
+	}
+
+	for importPath := range imports {
+		model.Imports = append(model.Imports, importPath)
+	}
+	sort.Strings(model.Imports)
 
 	if err := model.GenHeader(w, tmpl, needTime); err != nil {
 		return fmt.Errorf("[%s] Fail to gen model header, %s", tName, err)
@@ -143,7 +222,7 @@ func generateModel(dbName, tName string, schema TableSchema, config codeConfig,
 		return fmt.Errorf("[%s] Fail to gen model object api, %s", tName, err)
 	}
 
-	testFileName := path.Join(config.packageName, tName+"_test.go")
+	testFileName := path.Join(config.outputDir, tName+"_test.go")
 	if err := generateModelTest(model, tmpl, needTimeForTest, testFileName); err != nil {
 		return err
 	}
@@ -187,6 +266,8 @@ type ModelField struct {
 	Formatter        string
 	DefaultValueCode string
 	IgnoreOnInsert   bool
+	// The field is declared by the embedded struct, e.g. orm.Timestamps
+	InEmbed bool
 }
 
 func (f ModelField) ConverterFuncName() string {
@@ -238,6 +319,54 @@ func (pf PrimaryFields) FormatIncrementId() func() string {
 	}
 }
 
+func (pf PrimaryFields) FormatParams() func() string {
+	// id int64, or userId int64, articleId int64 for composite primary keys
+	return func() string {
+		params := make([]string, len(pf))
+		for i, field := range pf {
+			params[i] = fmt.Sprintf("%s %s", pf.paramName(field), field.Type)
+		}
+		return strings.Join(params, ", ")
+	}
+}
+
+func (pf PrimaryFields) FormatArgs() func() string {
+	// id, or userId, articleId for composite primary keys
+	return func() string {
+		args := make([]string, len(pf))
+		for i, field := range pf {
+			args[i] = pf.paramName(field)
+		}
+		return strings.Join(args, ", ")
+	}
+}
+
+func (pf PrimaryFields) FormatFieldArgs() func(string) string {
+	// obj.ArticleId, or obj.UserId, obj.ArticleId for composite primary keys
+	return func(name string) string {
+		args := make([]string, len(pf))
+		for i, field := range pf {
+			args[i] = fmt.Sprintf("%s.%s", name, field.Name)
+		}
+		return strings.Join(args, ", ")
+	}
+}
+
+// The identifiers of GetByPK, which can't be the param names
+var reservedParams = map[string]bool{"dao": true, "err": true, "sql": true}
+
+func (pf PrimaryFields) paramName(field *ModelField) string {
+	if len(pf) == 1 {
+		return "id"
+	}
+	// userId, or type_ for the keyword
+	name := strings.ToLower(field.Name[:1]) + field.Name[1:]
+	if token.IsKeyword(name) || reservedParams[name] {
+		name += "_"
+	}
+	return name
+}
+
 func (pf PrimaryFields) FormatFilters() func(string) string {
 	// filter := {{.Name}}Objs.Filter{{.PrimaryField.Name}}("=", obj.{{.PrimaryField.Name}})
 	return func(name string) string {
@@ -254,15 +383,43 @@ func (pf PrimaryFields) FormatFilters() func(string) string {
 }
 
 type ModelMeta struct {
-	Name         string
-	LowerName    string
-	DbName       string
-	TableName    string
-	DbString     string
-	PrimaryField *ModelField
-	Fields       []ModelField
-	Uniques      []ModelField
-	config codeConfig
+	Name          string
+	LowerName     string
+	DbName        string
+	TableName     string
+	DbString      string
+	PrimaryField  *ModelField
+	PrimaryFields PrimaryFields
+	Fields        []ModelField
+	Uniques       []ModelField
+	Embeds        []string
+	// import paths of the qualified types of the fields, e.g. github.com/shopspring/decimal
+	Imports []string
+	config  codeConfig
+}
+
+// Replace the created_at and updated_at fields by the embedded orm.Timestamps, if both of them are auto updated
+func (m *ModelMeta) embedTimestamps() bool {
+	idx := make([]int, 0, 2)
+	for i, f := range m.Fields {
+		if (f.ColumnName == "created_at" || f.ColumnName == "updated_at") && f.IgnoreOnInsert &&
+			f.Type == "time.Time" && f.Tag == "`ignore:\"true\"`" {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) != 2 {
+		return false
+	}
+	for _, i := range idx {
+		m.Fields[i].InEmbed = true
+	}
+	m.Embeds = append(m.Embeds, "orm.Timestamps")
+	return true
+}
+
+// Whether the table name can't be converted from the struct name, so that TableName() is generated for the orm
+func (m ModelMeta) NeedTableName() bool {
+	return toUnderscoreCase(m.Name) != m.TableName
 }
 
 func (m ModelMeta) AllFields() string {
@@ -350,6 +507,7 @@ func (m ModelMeta) GenHeader(w *bufio.Writer, tmpl *template.Template, importTim
 		"TableName":  m.TableName,
 		"PkgName":    m.config.packageName,
 		"ImportTime": importTime,
+		"Imports":    m.Imports,
 	})
 }
 
@@ -374,6 +532,22 @@ func (m ModelMeta) GenTestCode(w *bufio.Writer, tmpl *template.Template) error {
 	return m.getTemplate(tmpl, "test_code", tmTestCode).Execute(w, m)
 }
 
+// The column name converted from field name by the orm convention, i.e. TestOrmDId -> test_orm_d_id
+func toUnderscoreCase(name string) string {
+	buff := bytes.Buffer{}
+	for i, ch := range name {
+		if unicode.IsUpper(ch) {
+			if i > 0 {
+				buff.WriteString("_")
+			}
+			buff.WriteRune(unicode.ToLower(ch))
+		} else {
+			buff.WriteRune(ch)
+		}
+	}
+	return buff.String()
+}
+
 func toCapitalCase(name string, firstLetterUpper bool) string {
 	// cp___hello_12jiu -> CpHello12Jiu
 	data := []byte(name)
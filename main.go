@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -12,58 +13,89 @@ import (
 )
 
 func main() {
-	var targetDb, tableNames, packageName string
-	var tmplName string
-	var driver, schemaName string
-	var touchTimestamp bool
-	var pCount int
-	flag.StringVar(&targetDb, "db", "", "Target database source string: e.g. root@tcp(127.0.0.1:3306)/test?charset=utf-8")
-	flag.StringVar(&tableNames, "tables", "", "You may specify which tables the models need to be created, e.g. \"user,article,blog\"")
-	flag.StringVar(&packageName, "pkg", "", "Go source code package for generated models")
-	flag.StringVar(&driver, "driver", "mysql", "Current supported drivers include mysql, postgres")
-	flag.StringVar(&schemaName, "schema", "", "Schema for postgresql, database name for mysql")
-	flag.BoolVar(&touchTimestamp, "dont-touch-timestamp", false, "Should touch the datetime fields with default value or on update")
-	flag.StringVar(&tmplName, "template", "", "Passing the template to generate code, or use the default one")
-	flag.IntVar(&pCount, "p", 4, "Parallell running for code generator")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrateMain(os.Args[2:])
+		return
+	}
+
+	var configFile string
+	gc := defaultGeneratorConfig()
+	gc.bindFlags(flag.CommandLine)
+	flag.StringVar(&configFile, "config", defaultConfigFile, "Json config file of the generator, which is overridden by the flags")
 	flag.Parse()
 
-	runtime.GOMAXPROCS(pCount)
+	cfg, err := loadGeneratorConfig(configFile, flag.CommandLine)
+	if err != nil {
+		log.Fatalf("Cannot load the config file, %s", err)
+	}
+
+	runtime.GOMAXPROCS(cfg.Parallel)
 
-	if targetDb == "" {
-		fmt.Println("Please provide the target database source.")
+	if cfg.Db == "" && cfg.DDL == "" {
+		fmt.Println("Please provide the target database source or the ddl file.")
 		fmt.Println("Usage:")
 		flag.PrintDefaults()
 		return
 	}
-	if packageName == "" {
+	if cfg.Pkg == "" {
 		printUsages("Please provide the go source code package name for generated models.")
 		return
 	}
-	if driver != "mysql" {
+	if cfg.Driver != "mysql" {
 		printUsages("Current supported mysql driver.")
 		return
 	}
-	if schemaName == "" {
+	if cfg.Nullable != "pointer" && cfg.Nullable != "sql" {
+		printUsages("Nullable should be pointer or sql.")
+		return
+	}
+	if cfg.Schema == "" {
 		printUsages("Please provide the schema name.")
 		return
 	}
-
-	targetDb = wrapDbStringForMysql(targetDb)
-	dbSchema, err := loadDatabaseSchema(targetDb, schemaName, tableNames)
+	imports, err := cfg.resolveTypes()
 	if err != nil {
-		log.Println("Cannot load table schemas from database.")
-		log.Fatal(err)
+		printUsages(err.Error())
+		return
+	}
+
+	var targetDb string
+	var dbSchema DbSchema
+	if cfg.Db != "" {
+		targetDb = wrapDbStringForMysql(cfg.Db)
+	}
+	if cfg.DDL != "" {
+		dbSchema, err = loadDDLSchema(cfg.DDL, cfg.Schema, strings.Join(cfg.Tables, ","))
+		if err != nil {
+			log.Println("Cannot load table schemas from ddl file.")
+			log.Fatal(err)
+		}
+	} else {
+		dbSchema, err = loadDatabaseSchema(targetDb, cfg.Schema, strings.Join(cfg.Tables, ","))
+		if err != nil {
+			log.Println("Cannot load table schemas from database.")
+			log.Fatal(err)
+		}
 	}
 
 	codeConfig := &codeConfig{
-		packageName:    packageName,
-		touchTimestamp: touchTimestamp,
-		template:       tmplName,
-		dbString:       targetDb,
+		packageName:     cfg.Pkg,
+		touchTimestamp:  cfg.DontTouchTimestamp,
+		embedTimestamps: cfg.EmbedTimestamps,
+		nullStyle:       cfg.Nullable,
+		jsonType:        cfg.JsonType,
+		decimalType:     cfg.Decimal,
+		typeMap:         cfg.Types,
+		imports:         imports,
+		template:        cfg.Template,
+		dbString:        targetDb,
+		outputDir:       cfg.Output,
+		renames:         cfg.Renames,
+		exclude:         cfg.Exclude,
 	}
 	codeConfig.MustCompileTemplate()
-	generateModels(schemaName, dbSchema, *codeConfig)
-	formatCodes(packageName)
+	generateModels(cfg.Schema, dbSchema, *codeConfig)
+	formatCodes(cfg.Output)
 }
 
 func formatCodes(pkg string) {
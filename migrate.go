@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/zhengyun1112/glorm/orm"
+	"github.com/zhengyun1112/glorm/orm/migrate"
+	"log"
+	"os"
+)
+
+const migrateUsage = `Usage: glorm migrate [flags] up|down|status|redo
+  up      apply all the pending migrations
+  down    roll back the last applied migration
+  status  show the applied and pending migrations
+  redo    roll back the last applied migration and apply it again`
+
+// The glorm migrate command, which applies the migration files of the directory to the database
+func migrateMain(args []string) {
+	var configFile string
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.String("db", "", "Target database source string: e.g. root@tcp(127.0.0.1:3306)/test?charset=utf-8")
+	fs.String("migrations", "migrations", "Directory of the migration files, e.g. 0001_create_user.up.sql and 0001_create_user.down.sql")
+	fs.StringVar(&configFile, "config", defaultConfigFile, "Json config file of the generator, which is overridden by the flags")
+	fs.Usage = func() {
+		fmt.Println(migrateUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg, err := loadGeneratorConfig(configFile, fs)
+	if err != nil {
+		log.Fatalf("Cannot load the config file, %s", err)
+	}
+	if cfg.Db == "" || fs.NArg() != 1 || !migrateCommands[fs.Arg(0)] {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	migrations, err := migrate.LoadDir(cfg.Migrations)
+	if err != nil {
+		log.Fatalf("Cannot load the migrations, %s", err)
+	}
+	m, err := orm.Open(wrapDbStringForMysql(cfg.Db))
+	if err != nil {
+		log.Fatalf("Cannot open the database, %s", err)
+	}
+	err = runMigrate(m, migrations, fs.Arg(0))
+	// log.Fatal exits without the deferred calls
+	m.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+var migrateCommands = map[string]bool{"up": true, "down": true, "status": true, "redo": true}
+
+// Run the migrate command on the database
+func runMigrate(m *orm.ORM, migrations []*migrate.Migration, command string) error {
+	migrator, err := migrate.New(m, migrations)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		for _, mig := range applied {
+			log.Println("Applied", mig)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("No pending migration")
+		}
+	case "down":
+		mig, err := migrator.Down()
+		if errors.Is(err, migrate.ErrNoMigration) {
+			log.Println("No applied migration")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Println("Rolled back", mig)
+	case "redo":
+		mig, err := migrator.Redo()
+		if err != nil {
+			return err
+		}
+		log.Println("Redone", mig)
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s %-20s %s\n", "Version", "Applied At", "Name")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			name := s.Name
+			if s.Missing {
+				name += " (missing)"
+			}
+			fmt.Printf("%-20d %-20s %s\n", s.Version, appliedAt, name)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %s", command)
+	}
+	return nil
+}
@@ -13,13 +13,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"github.com/zhengyun1112/glorm/orm"
-	{{if .ImportTime}}"time"{{end}}
+	{{if .ImportTime}}"time"{{end}}{{range .Imports}}
+	"{{.}}"{{end}}
 )
 `
 
 var modelStruct string = `type {{.Name}} struct {
-	{{range .Fields}}{{.Name}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
-	{{end}}
+	{{range .Embeds}}{{.}}
+	{{end}}{{range .Fields}}{{if not .InEmbed}}{{.Name}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
+	{{end}}{{end}}
 }
 
 func (obj {{.Name}}) MarshalJSON() ([]byte, error) {
@@ -28,7 +30,11 @@ func (obj {{.Name}}) MarshalJSON() ([]byte, error) {
 	{{end}}
 	})
 }
-`
+{{if .NeedTableName}}
+func ({{.Name}}) TableName() string {
+	return "{{.TableName}}"
+}
+{{end}}`
 
 var objApi string = `
 // Start of the {{.Name}} APIs.
@@ -41,9 +47,53 @@ func (dao _{{.Name}}Dao) Insert({{.LowerName}} *{{.Name}}) error {
 	return dao.m.Insert({{.LowerName}})
 }
 
-func (dao _{{.Name}}Dao) GetByPK(id {{.PrimaryField.Type}}) (*{{.Name}}, error) {
+{{if .PrimaryFields}}
+func (dao _{{.Name}}Dao) GetByPK({{call .PrimaryFields.FormatParams}}) (*{{.Name}}, error) {
+	var {{.LowerName}} {{.Name}}
+	err := dao.m.SelectByPK(&{{.LowerName}}, {{call .PrimaryFields.FormatArgs}})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err == nil {
+		return &{{.LowerName}}, nil
+	} else {
+		return nil, err
+	}
+}
+{{end}}
+
+// Start of the {{.Name}} filters, e.g. {{.Name}}Objs.Filter{{(index .Fields 0).Name}}("=", value).List()
+
+type _{{.Name}}Objs struct{}
+
+type {{.Name}}Filter struct {
+	filter orm.Filter
+}
+{{range .Fields}}
+func (objs _{{$.Name}}Objs) Filter{{.Name}}(op string, value interface{}) {{$.Name}}Filter {
+	return {{$.Name}}Filter{orm.NewFilter("{{.ColumnName}}", op, value)}
+}
+{{end}}
+func (f {{.Name}}Filter) And(other {{.Name}}Filter) {{.Name}}Filter {
+	return {{.Name}}Filter{f.filter.And(other.filter)}
+}
+
+func (f {{.Name}}Filter) Or(other {{.Name}}Filter) {{.Name}}Filter {
+	return {{.Name}}Filter{f.filter.Or(other.filter)}
+}
+
+func (f {{.Name}}Filter) query(s interface{}) *orm.QueryBuilder {
+	return {{.Name}}Dao.m.Query(s).Filter(f.filter)
+}
+
+func (f {{.Name}}Filter) List() ([]*{{.Name}}, error) {
+	var list []*{{.Name}}
+	err := f.query(&list).All()
+	return list, err
+}
+
+func (f {{.Name}}Filter) One() (*{{.Name}}, error) {
 	var {{.LowerName}} {{.Name}}
-	err := dao.m.SelectByPK(&{{.LowerName}}, id)
+	err := f.query(&{{.LowerName}}).One()
 	if err == sql.ErrNoRows {
 		return nil, nil
 	} else if err == nil {
@@ -53,7 +103,16 @@ func (dao _{{.Name}}Dao) GetByPK(id {{.PrimaryField.Type}}) (*{{.Name}}, error)
 	}
 }
 
+func (f {{.Name}}Filter) Count() (int64, error) {
+	return f.query(&{{.Name}}{}).Count()
+}
+
+func (f {{.Name}}Filter) Delete() (int64, error) {
+	return f.query(&{{.Name}}{}).Delete()
+}
+
 var {{.Name}}Dao _{{.Name}}Dao
+var {{.Name}}Objs _{{.Name}}Objs
 
 func init() {
 	{{.Name}}Dao.m = orm.Default // You can replace the ORM with your customized one instead of Default
@@ -85,13 +144,19 @@ func TestInsertAndGet{{.Name}}(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to Insert{{.Name}}, err: %+v", err)
 	}
-	loaded, err := {{.Name}}Dao.GetByPK({{.LowerName}}.{{.PrimaryField.Name}})
+	{{if .PrimaryFields}}loaded, err := {{.Name}}Dao.GetByPK({{call .PrimaryFields.FormatFieldArgs .LowerName}})
 	if err != nil {
 		t.Fatalf("failed to GetByPK, err: %+v", err)
 	}
 	if loaded == nil {
 		t.Fatalf("should have loaded one {{.Name}}")
 	}
+
+	obj := loaded
+	{{call .PrimaryFields.FormatFilters .Name}}
+	if n, err := filter.Count(); err != nil || n != 1 {
+		t.Fatalf("should have filtered one {{.Name}}, count: %d, err: %+v", n, err)
+	}{{end}}
 }
 `
 
@@ -0,0 +1,207 @@
+package orm
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Converter converts the field of a Go type which is not supported by the driver, i.e. []string, map or net.IP,
+// to the column value and back. It's registered for a field type by RegisterConverter, or for the fields with
+// tag `conv:"name"` by RegisterTagConverter, json and csv are registered by default, as well as json.RawMessage.
+// The converters should be registered before the models are used, since the converter of a field is resolved
+// once for the model type
+type Converter interface {
+	// Convert the field value to the column value, which should be nil, int64, float64, bool, []byte,
+	// string or time.Time
+	ToColumn(field reflect.Value) (interface{}, error)
+	// Set the field by the scanned column value, which is one of the column value types.
+	// The []byte is only valid during the call, copy it if it's kept by the field
+	FromColumn(column interface{}, field reflect.Value) error
+}
+
+var (
+	convLock       sync.RWMutex
+	typeConverters = map[reflect.Type]Converter{
+		reflect.TypeOf(json.RawMessage{}): rawJSONConverter{},
+	}
+	tagConverters = map[string]Converter{
+		"json": jsonConverter{},
+		"csv":  csvConverter{},
+	}
+)
+
+// Register the converter of the fields of the same type as typ, e.g. RegisterConverter(net.IP{}, ipConverter)
+func RegisterConverter(typ interface{}, c Converter) {
+	convLock.Lock()
+	defer convLock.Unlock()
+	typeConverters[reflect.TypeOf(typ)] = c
+}
+
+// Register the converter of the fields with tag `conv:"name"`
+func RegisterTagConverter(name string, c Converter) {
+	convLock.Lock()
+	defer convLock.Unlock()
+	tagConverters[name] = c
+}
+
+// The converter of the struct field, the tag converter goes before the type converter
+func fieldConverter(ft reflect.StructField) Converter {
+	convLock.RLock()
+	defer convLock.RUnlock()
+	if name := ft.Tag.Get("conv"); name != "" {
+		c, ok := tagConverters[name]
+		if !ok {
+			panic(errors.New("unregistered converter " + name + " on field: " + ft.Name))
+		}
+		return c
+	}
+	return typeConverters[ft.Type]
+}
+
+// The converter of the type, nil if there isn't
+func typeConverter(t reflect.Type) Converter {
+	convLock.RLock()
+	defer convLock.RUnlock()
+	return typeConverters[t]
+}
+
+// convField converts the field by its converter when it's written as an arg, or scanned as a target
+type convField struct {
+	conv  Converter
+	field reflect.Value
+}
+
+func (cf convField) Value() (driver.Value, error) {
+	return cf.conv.ToColumn(cf.field)
+}
+
+func (cf convField) Scan(src interface{}) error {
+	return cf.conv.FromColumn(src, cf.field)
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// The string of the column value, []byte is converted to string
+func columnString(column interface{}) (string, bool) {
+	switch t := column.(type) {
+	case []byte:
+		return string(t), true
+	case string:
+		return t, true
+	}
+	return "", false
+}
+
+// jsonConverter stores the field as json, NULL for the nil field
+type jsonConverter struct{}
+
+func (c jsonConverter) ToColumn(field reflect.Value) (interface{}, error) {
+	if isNilValue(field) {
+		return nil, nil
+	}
+	b, err := json.Marshal(field.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (c jsonConverter) FromColumn(column interface{}, field reflect.Value) error {
+	field.Set(reflect.Zero(field.Type()))
+	if column == nil {
+		return nil
+	}
+	s, ok := columnString(column)
+	if !ok {
+		return fmt.Errorf("can not convert %T to json", column)
+	}
+	return json.Unmarshal([]byte(s), field.Addr().Interface())
+}
+
+// rawJSONConverter stores json.RawMessage as string, since MySQL can't create json from binary string,
+// NULL for the empty message
+type rawJSONConverter struct{}
+
+func (c rawJSONConverter) ToColumn(field reflect.Value) (interface{}, error) {
+	if field.Len() == 0 {
+		return nil, nil
+	}
+	return string(field.Bytes()), nil
+}
+
+func (c rawJSONConverter) FromColumn(column interface{}, field reflect.Value) error {
+	field.Set(reflect.Zero(field.Type()))
+	if column == nil {
+		return nil
+	}
+	s, ok := columnString(column)
+	if !ok {
+		return fmt.Errorf("can not convert %T to json", column)
+	}
+	field.SetBytes([]byte(s))
+	return nil
+}
+
+// csvConverter stores the slice of string as a csv line, i.e. []string{"a", "b,c"} -> a,"b,c"
+type csvConverter struct{}
+
+func (c csvConverter) ToColumn(field reflect.Value) (interface{}, error) {
+	if field.Kind() != reflect.Slice || field.Type().Elem().Kind() != reflect.String {
+		return nil, fmt.Errorf("csv type %s %w", field.Type(), ErrUnsupportedType)
+	}
+	if field.IsNil() {
+		return nil, nil
+	}
+	record := make([]string, field.Len())
+	for i := range record {
+		record[i] = field.Index(i).String()
+	}
+	buff := bytes.Buffer{}
+	w := csv.NewWriter(&buff)
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return strings.TrimSuffix(buff.String(), "\n"), w.Error()
+}
+
+func (c csvConverter) FromColumn(column interface{}, field reflect.Value) error {
+	if field.Kind() != reflect.Slice || field.Type().Elem().Kind() != reflect.String {
+		return fmt.Errorf("csv type %s %w", field.Type(), ErrUnsupportedType)
+	}
+	field.Set(reflect.Zero(field.Type()))
+	if column == nil {
+		return nil
+	}
+	s, ok := columnString(column)
+	if !ok {
+		return fmt.Errorf("can not convert %T to csv", column)
+	}
+	if s == "" {
+		field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		return nil
+	}
+	record, err := csv.NewReader(strings.NewReader(s)).Read()
+	if err != nil {
+		return err
+	}
+	ret := reflect.MakeSlice(field.Type(), len(record), len(record))
+	for i, r := range record {
+		ret.Index(i).SetString(r)
+	}
+	field.Set(ret)
+	return nil
+}
@@ -0,0 +1,245 @@
+package orm
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Dialect hides the SQL differences between databases. The queries are written in MySQL style,
+// i.e. `?` placeholders and backtick quoted identifiers, and rebound to the dialect before executed.
+// The driver of the dialect should be imported by the user, except for mysql
+type Dialect interface {
+	// Name of the database/sql driver
+	DriverName() string
+	// Quote the identifier, i.e. table and column names
+	Quote(string) string
+	// Placeholder of the nth arg, starting from 1
+	Placeholder(int) string
+	// Whether to get the auto increment id by INSERT ... RETURNING instead of LastInsertId
+	InsertReturning() bool
+	// The LIMIT/OFFSET clause, limit is negative if it's not limited
+	LimitOffset(int64, int64) string
+	// The SQL to remove all the rows of the table
+	TruncateTable(string) string
+	// Get the column names of the table
+	Columns(Tdx, string) ([]string, error)
+	// Whether DDL could be rolled back in a transaction, MySQL commits the transaction implicitly by DDL
+	TransactionalDDL() bool
+}
+
+var (
+	MySQL      Dialect = mysqlDialect{}
+	PostgreSQL Dialect = postgresDialect{}
+	SQLite     Dialect = sqliteDialect{}
+)
+
+type mysqlDialect struct{}
+
+func (d mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+func (d mysqlDialect) Quote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func (d mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (d mysqlDialect) InsertReturning() bool {
+	return false
+}
+
+func (d mysqlDialect) TransactionalDDL() bool {
+	return false
+}
+
+func (d mysqlDialect) LimitOffset(limit int64, offset int64) string {
+	if limit < 0 && offset > 0 {
+		// MySQL doesn't support offset without limit
+		return " LIMIT 18446744073709551615 OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	return limitOffset(limit, offset)
+}
+
+func (d mysqlDialect) TruncateTable(table string) string {
+	return "truncate table " + d.Quote(table)
+}
+
+func (d mysqlDialect) Columns(tdx Tdx, table string) ([]string, error) {
+	ret := []string{}
+	rows, err := tdx.Query("show columns from " + d.Quote(table))
+	if err != nil {
+		return ret, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, tp, nu, key, dft, extra sql.NullString
+		if err := rows.Scan(&name, &tp, &nu, &key, &dft, &extra); err != nil {
+			return ret, errors.New("can not scan filed:" + err.Error())
+		}
+		ret = append(ret, name.String)
+	}
+	if err := rows.Err(); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+type postgresDialect struct{}
+
+func (d postgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (d postgresDialect) Quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (d postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (d postgresDialect) InsertReturning() bool {
+	return true
+}
+
+func (d postgresDialect) TransactionalDDL() bool {
+	return true
+}
+
+func (d postgresDialect) LimitOffset(limit int64, offset int64) string {
+	return limitOffset(limit, offset)
+}
+
+func (d postgresDialect) TruncateTable(table string) string {
+	return "TRUNCATE TABLE " + d.Quote(table) + " RESTART IDENTITY"
+}
+
+func (d postgresDialect) Columns(tdx Tdx, table string) ([]string, error) {
+	return selectColumnNames(tdx, "SELECT column_name FROM information_schema.columns "+
+		"WHERE table_schema = current_schema() AND table_name = ? ORDER BY ordinal_position", table)
+}
+
+type sqliteDialect struct{}
+
+func (d sqliteDialect) DriverName() string {
+	return "sqlite3"
+}
+
+func (d sqliteDialect) Quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (d sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// LastInsertId of sqlite is the id of the last row for batch insert, so RETURNING is used instead
+func (d sqliteDialect) InsertReturning() bool {
+	return true
+}
+
+func (d sqliteDialect) TransactionalDDL() bool {
+	return true
+}
+
+func (d sqliteDialect) LimitOffset(limit int64, offset int64) string {
+	if limit < 0 && offset > 0 {
+		// SQLite doesn't support offset without limit
+		return " LIMIT -1 OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	return limitOffset(limit, offset)
+}
+
+// SQLite doesn't have TRUNCATE, and DELETE without WHERE is optimized as truncate
+func (d sqliteDialect) TruncateTable(table string) string {
+	return "DELETE FROM " + d.Quote(table)
+}
+
+func (d sqliteDialect) Columns(tdx Tdx, table string) ([]string, error) {
+	// the columns of table_info are cid, name, type, notnull, dflt_value, pk
+	return selectColumnNames(tdx, "SELECT name FROM pragma_table_info(?) ORDER BY cid", table)
+}
+
+func limitOffset(limit int64, offset int64) string {
+	ret := ""
+	if limit >= 0 {
+		ret += " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	if offset > 0 {
+		ret += " OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	return ret
+}
+
+func selectColumnNames(tdx Tdx, query string, table string) ([]string, error) {
+	ret := []string{}
+	rows, err := tdx.Query(query, table)
+	if err != nil {
+		return ret, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return ret, errors.New("can not scan filed:" + err.Error())
+		}
+		ret = append(ret, name)
+	}
+	if err := rows.Err(); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+// Rebind the MySQL style query to the dialect, i.e. the `?` placeholders and backtick quoted identifiers.
+// The string literals and the identifiers quoted by the dialect are kept as is
+func rebind(d Dialect, query string) string {
+	if _, ok := d.(mysqlDialect); ok || d == nil {
+		return query
+	}
+	buff := bytes.Buffer{}
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch c {
+		case '\'', '"':
+			// copy until the closing quote, the escaped quote is doubled
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(query) {
+				j = len(query) - 1
+			}
+			buff.WriteString(query[i : j+1])
+			i = j
+		case '`':
+			j := strings.IndexByte(query[i+1:], '`')
+			if j < 0 {
+				buff.WriteString(query[i:])
+				i = len(query)
+				continue
+			}
+			buff.WriteString(d.Quote(query[i+1 : i+1+j]))
+			i += j + 1
+		case '?':
+			n++
+			buff.WriteString(d.Placeholder(n))
+		default:
+			buff.WriteByte(c)
+		}
+	}
+	return buff.String()
+}
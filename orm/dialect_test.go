@@ -0,0 +1,167 @@
+package orm
+
+import (
+	"context"
+	_ "github.com/mattn/go-sqlite3"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The tables of oneTestScope and the tests in SQLite
+var sqliteTestTables = []string{
+	`CREATE TABLE test_orm_a123 (
+	  test_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  other_id BIGINT NOT NULL,
+	  description VARCHAR(1024) NOT NULL,
+	  name VARCHAR(50) NULL,
+	  start_date DATETIME NULL,
+	  end_date DATETIME NULL,
+	  test_orm_d_id BIGINT NOT NULL DEFAULT 0,
+	  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+	`CREATE TABLE test_orm_b999 (
+	  no_ai_id BIGINT NOT NULL PRIMARY KEY,
+	  description VARCHAR(1024) NOT NULL,
+	  end_date TIMESTAMP NOT NULL,
+	  test_id BIGINT NOT NULL,
+	  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+	`CREATE TABLE test_orm_c111 (
+	  test_orm_c_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  name VARCHAR(1024) NOT NULL,
+	  test_id BIGINT NOT NULL)`,
+	`CREATE TABLE test_orm_d222 (
+	  test_orm_d_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  name VARCHAR(1024) NOT NULL)`,
+	`CREATE TABLE test_orm_e333 (
+	  test_orm_e_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  title VARCHAR(1024) NOT NULL,
+	  state INT NOT NULL)`,
+	`CREATE TABLE test_orm_f444 (
+	  test_id BIGINT NOT NULL,
+	  test_orm_c_id BIGINT NOT NULL,
+	  weight INT NOT NULL,
+	  PRIMARY KEY (test_id, test_orm_c_id))`,
+}
+
+// Same as oneTestScope, but on a new in-memory SQLite database, so that the tests run without MySQL server
+func sqliteTestScope(fn func(orm *ORM)) {
+	// the in-memory database lives in its only connection
+	orm, err := NewORMWithOptions(":memory:", Options{Dialect: SQLite, MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		panic(err)
+	}
+	defer orm.Close()
+	for _, ddl := range sqliteTestTables {
+		if _, err := orm.Exec(ddl); err != nil {
+			panic(err)
+		}
+	}
+	fn(orm)
+}
+
+var (
+	mysqlOnce      sync.Once
+	mysqlAvailable bool
+)
+
+// Run fn in the scope of SQLite and then MySQL, which is skipped if the server is unavailable
+func eachTestScope(t *testing.T, fn func(t *testing.T, orm *ORM)) {
+	t.Run("sqlite", func(t *testing.T) {
+		sqliteTestScope(func(orm *ORM) {
+			fn(t, orm)
+		})
+	})
+	t.Run("mysql", func(t *testing.T) {
+		mysqlOnce.Do(func() {
+			_, err := Open("root:@/test?parseTime=true&loc=Local")
+			mysqlAvailable = err == nil
+		})
+		if !mysqlAvailable {
+			t.Skip("mysql is unavailable")
+		}
+		oneTestScope(func(orm *ORM) {
+			fn(t, orm)
+		})
+	})
+}
+
+func TestDialectSQL(t *testing.T) {
+	cases := []struct {
+		dialect  Dialect
+		limit    string
+		offset   string
+		truncate string
+	}{
+		{MySQL, " LIMIT 10 OFFSET 5", " LIMIT 18446744073709551615 OFFSET 5", "truncate table `a`"},
+		{PostgreSQL, " LIMIT 10 OFFSET 5", " OFFSET 5", `TRUNCATE TABLE "a" RESTART IDENTITY`},
+		{SQLite, " LIMIT 10 OFFSET 5", " LIMIT -1 OFFSET 5", `DELETE FROM "a"`},
+	}
+	for _, c := range cases {
+		if ret := c.dialect.LimitOffset(10, 5); ret != c.limit {
+			t.Errorf("%s: incorrect limit %q", c.dialect.DriverName(), ret)
+		}
+		if ret := c.dialect.LimitOffset(-1, 5); ret != c.offset {
+			t.Errorf("%s: incorrect offset %q", c.dialect.DriverName(), ret)
+		}
+		if ret := c.dialect.LimitOffset(-1, 0); ret != "" {
+			t.Errorf("%s: should not limit %q", c.dialect.DriverName(), ret)
+		}
+		if ret := c.dialect.TruncateTable("a"); ret != c.truncate {
+			t.Errorf("%s: incorrect truncate %q", c.dialect.DriverName(), ret)
+		}
+	}
+}
+
+func TestSQLiteDialect(t *testing.T) {
+	sqliteTestScope(func(orm *ORM) {
+		// the auto increment ids are returned by RETURNING
+		obj := &TestOrmA123{OtherId: 1, Description: "a", StartDate: time.Now(), EndDate: time.Now()}
+		if err := orm.Insert(obj); err != nil || obj.TestId != 1 {
+			t.Fatal("failed to insert", obj.TestId, err)
+		}
+		batch := []interface{}{
+			&TestOrmA123{OtherId: 2, Description: "b"},
+			&TestOrmA123{OtherId: 3, Description: "c"},
+			&TestOrmA123{OtherId: 4, Description: "d"},
+		}
+		if err := orm.InsertBatch(batch); err != nil {
+			t.Fatal(err)
+		}
+		for i, obj := range batch {
+			if id := obj.(*TestOrmA123).TestId; id != int64(i+2) {
+				t.Fatal("incorrect id of batch insert", i, id)
+			}
+		}
+
+		var loaded TestOrmA123
+		if err := orm.SelectByPK(&loaded, 4); err != nil || loaded.OtherId != 4 || loaded.CreatedAt.IsZero() {
+			t.Fatal("failed to select", loaded, err)
+		}
+
+		// the columns are checked by pragma_table_info
+		cols, err := SQLite.Columns(orm.session(context.Background()), "test_orm_c111")
+		if err != nil || !reflect.DeepEqual(cols, []string{"test_orm_c_id", "name", "test_id"}) {
+			t.Fatal("incorrect columns", cols, err)
+		}
+		orm.AddTable(TestOrmA123{})
+		orm.AddTable(TestOrmF444{})
+		if err := orm.CheckTables(); err != nil {
+			t.Fatal(err)
+		}
+
+		var rest []*TestOrmA123
+		if err := orm.Query(&rest).OrderBy("test_id").Offset(2).All(); err != nil || len(rest) != 2 || rest[0].TestId != 3 {
+			t.Fatal("incorrect offset", rest, err)
+		}
+
+		if err := orm.TruncateTable("test_orm_a123"); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := orm.SelectInt("select count(*) from `test_orm_a123`"); err != nil || n != 0 {
+			t.Fatal("should truncate the table", n, err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// The table of the model does not have `pk:"true"` field
+	ErrNoPrimaryKey = errors.New("does not have primary key")
+	// The struct field of a column or param is missing, the MissingFieldError has the detail
+	ErrMissingField = errors.New("missing field")
+	// The type of holder or param is not supported
+	ErrUnsupportedType = errors.New("not supported")
+)
+
+// RowAffectError is returned when the query does not affect the expected number of rows
+type RowAffectError struct {
+	Query    string
+	Expected int64
+	Actual   int64
+}
+
+func (e *RowAffectError) Error() string {
+	return fmt.Sprintf("[RowAffectCheckError]: query [%s] should only affect %d rows, really affect %d rows",
+		e.Query, e.Expected, e.Actual)
+}
+
+// MissingFieldError is returned when there is no struct field for the column or param,
+// Table is empty if the field is not checked against a table
+type MissingFieldError struct {
+	Table  string
+	Column string
+}
+
+func (e *MissingFieldError) Error() string {
+	if e.Table == "" {
+		return "missing field " + e.Column
+	}
+	return e.Table + " missing field " + e.Column
+}
+
+func (e *MissingFieldError) Is(target error) bool {
+	return target == ErrMissingField
+}
+
+// TableCheckError is returned by CheckTables, which has all the mismatched tables and columns
+type TableCheckError struct {
+	Errors []error
+}
+
+func (e *TableCheckError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "can not pass table check: " + strings.Join(msgs, "; ")
+}
+
+func (e *TableCheckError) Unwrap() []error {
+	return e.Errors
+}
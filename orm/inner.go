@@ -2,6 +2,7 @@ package orm
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -55,70 +56,70 @@ func reflectStructValue(v reflect.Value, cols []string, row *sql.Rows) error {
 		panic(errors.New("holder should be pointer"))
 	}
 	v = v.Elem()
-	targets := make([]interface{}, len(cols))
-	for k, c := range cols {
-		fv := v.FieldByName(colName2FieldName(c))
-		if !fv.CanAddr() {
-			log.Println("missing filed", c)
-			var b interface{}
-			targets[k] = &b
-		} else {
-			targets[k] = fv.Addr().Interface()
-		}
-	}
-	err := row.Scan(targets...)
+	mi := getModelInfo(v.Type())
+	err := row.Scan(scanTargets(v, mi.fieldsOf(cols))...)
 	if err != nil {
 		return err
 	}
+	recordSnapshot(v, mi)
 	return nil
 }
 
-func checkStruct(s interface{}, cols []string, tableName string) error {
-
-	v := reflect.TypeOf(s)
-
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
+// Check all the columns have struct fields, a MissingFieldError for each missing one
+func checkStruct(s interface{}, cols []string, tableName string) []error {
+	mi := modelInfoOf(s)
+	missing := make([]error, 0)
 	for _, c := range cols {
-		_, ok := v.FieldByName(colName2FieldName(c))
-		if !ok {
-			return errors.New(tableName + " missing field " + c)
+		if mi.fieldOf(c) == nil {
+			missing = append(missing, &MissingFieldError{Table: tableName, Column: c})
 		}
 	}
-	return nil
+	return missing
 }
 
 type Tdx interface {
 	Exec(string, ...interface{}) (sql.Result, error)
 	Query(string, ...interface{}) (*sql.Rows, error)
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
 }
 
-func getColumns(tdx Tdx, tableName string) ([]string, error) {
-	ret := []string{}
-	rows, err := tdx.Query("show columns from " + tableName)
-	if err != nil {
-		return ret, err
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var name, tp, nu, key, dft, extra sql.NullString
-		if err := rows.Scan(&name, &tp, &nu, &key, &dft, &extra); err != nil {
-			return ret, errors.New("can not scan filed:" + err.Error())
-		}
-		ret = append(ret, name.String)
+// session binds the context and dialect to Tdx, so that all the queries of an ORM operation are
+// executed with the context, and rebound to the dialect
+type session struct {
+	Tdx
+	ctx     context.Context
+	dialect Dialect
+}
+
+func newSession(tdx Tdx, ctx context.Context, dialect Dialect) Tdx {
+	if dialect == nil {
+		dialect = MySQL
 	}
-	if err := rows.Err(); err != nil {
-		return ret, err
+	return session{Tdx: tdx, ctx: ctx, dialect: dialect}
+}
+
+func (s session) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.Tdx.ExecContext(s.ctx, rebind(s.dialect, query), args...)
+}
+
+func (s session) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.Tdx.QueryContext(s.ctx, rebind(s.dialect, query), args...)
+}
+
+// The dialect of the session, MySQL for the raw Tdx
+func dialectOf(tdx Tdx) Dialect {
+	if s, ok := tdx.(session); ok {
+		return s.dialect
 	}
-	return ret, nil
+	return MySQL
 }
 
-func checkTableColumns(tdx Tdx, s interface{}) error {
+func checkTableColumns(tdx Tdx, s interface{}) []error {
 	tableName := getTableName(s)
-	cols, err := getColumns(tdx, tableName)
+	cols, err := dialectOf(tdx).Columns(tdx, tableName)
 	if err != nil {
-		return err
+		return []error{err}
 	}
 	log.Println(tableName, cols)
 	return checkStruct(s, cols, tableName)
@@ -158,149 +159,147 @@ func execWithRowAffectCheck(tdx Tdx, expectRows int64, query string, args ...int
 		return err
 	}
 	if ra != expectRows {
-		return errors.New(fmt.Sprintf("[RowAffectCheckError]: query [%s] should only affect %d rows, really affect %d rows", query, expectRows, ra))
+		return &RowAffectError{Query: query, Expected: expectRows, Actual: ra}
 	}
 	return nil
 }
 
-func getPKColumn(s interface{}) string {
-	t := reflect.TypeOf(s).Elem()
-	return getPkColumnByType(t)
+func getPKColumns(s interface{}) []string {
+	return modelInfoOf(s).pkColumns()
+}
+
+// Get all the primary key columns in the order of struct fields, there are more than one for composite primary key
+func getPkColumnsByType(t reflect.Type) []string {
+	return getModelInfo(t).pkColumns()
+}
+
+// Check the number of given pk values matches the primary key columns
+func checkPKValues(tabname string, pkCols []string, pks []interface{}) error {
+	if len(pkCols) == 0 {
+		return fmt.Errorf("%s %w", tabname, ErrNoPrimaryKey)
+	}
+	if len(pks) != len(pkCols) {
+		return errors.New(fmt.Sprintf("%s has %d primary key columns, but %d values are given", tabname, len(pkCols), len(pks)))
+	}
+	return nil
+}
+
+// Where condition of the given columns, i.e. `a` = ? AND `b` = ?
+func whereClause(cols []string) string {
+	conds := make([]string, len(cols))
+	for i, c := range cols {
+		conds[i] = "`" + c + "` = ?"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// In condition of the given columns, i.e. `a` in (?,?) or (`a`,`b`) in ((?,?),(?,?)) for composite keys
+func inClause(cols []string, keys [][]interface{}) (string, []interface{}) {
+	args := make([]interface{}, 0, len(cols)*len(keys))
+	buff := bytes.Buffer{}
+	if len(cols) == 1 {
+		buff.WriteString("`" + cols[0] + "` in (")
+	} else {
+		buff.WriteString("(`" + strings.Join(cols, "`,`") + "`) in (")
+	}
+	for i, key := range keys {
+		if i > 0 {
+			buff.WriteString(",")
+		}
+		if len(cols) == 1 {
+			buff.WriteString("?")
+		} else {
+			buff.WriteString("(" + strings.Repeat(",?", len(cols))[1:] + ")")
+		}
+		args = append(args, key...)
+	}
+	buff.WriteString(")")
+	return buff.String(), args
 }
 
-func getPkColumnByType(t reflect.Type) string {
-	for k := 0; k < t.NumField(); k++ {
-		ft := t.Field(k)
-		if ft.Tag.Get("pk") == "true" {
-			return fieldName2ColName(ft.Name)
+// Comparable key of a tuple of column values, used to match the relation rows. The values are normalized,
+// so that the key fields of different types match by value, e.g. int and int64
+func tupleKey(values []interface{}) string {
+	normalized := make([]interface{}, len(values))
+	for i, v := range values {
+		normalized[i] = keyValue(v)
+	}
+	return fmt.Sprintf("%#v", normalized)
+}
+
+// The key value of v, which is int64 for the integers, uint64 for the unsigned ones and string for []byte
+func keyValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes())
 		}
 	}
-	return ""
+	return v
 }
 
 type orColumn struct {
 	fieldName string
 	or        string
-	table     string
-	orType    reflect.Type
+	// table of tag table, which is the table of orType by default
+	table  string
+	orType reflect.Type
+	// columns of tag fk and ref, which are empty by default
+	fk  []string
+	ref []string
+	// error of the invalid relation, i.e. the field of has_one is not pointer
+	err error
 }
 
-func getOrColumns(s interface{}) (reflect.StructField, []*orColumn) {
-	t := reflect.TypeOf(s).Elem()
-	return getOrColumnsByType(t)
-}
-
-func getOrColumnsByType(t reflect.Type) (reflect.StructField, []*orColumn) {
-	res := make([]*orColumn, 0)
-	pkColumn := reflect.StructField{}
-	// TODO: error check, i.e., has_one field must be a pointer of registered model
-	for k := 0; k < t.NumField(); k++ {
-		ft := t.Field(k)
-		orTag := ft.Tag.Get("or")
-		if orTag != "" {
-			if orTag == TAG_HAS_ONE || orTag == TAG_HAS_MANY || orTag == TAG_BELONGS_TO {
-				var orType reflect.Type
-				if orTag == TAG_HAS_ONE {
-					if ft.Type.Kind() != reflect.Ptr {
-						panic(errors.New(ft.Name + " should be pointer"))
-					}
-					orType = ft.Type.Elem()
-				} else if orTag == TAG_HAS_MANY {
-					if ft.Type.Kind() != reflect.Slice {
-						panic(errors.New(ft.Name + " should be slice of pointer"))
-					}
-					elemType := ft.Type.Elem()
-					if elemType.Kind() != reflect.Ptr {
-						panic(errors.New(ft.Name + " should be slice of pointer"))
-					}
-					orType = elemType.Elem()
-				} else if orTag == TAG_BELONGS_TO {
-					if ft.Type.Kind() != reflect.Ptr {
-						panic(errors.New(ft.Name + " should be pointer"))
-					}
-					orType = ft.Type.Elem()
-				}
-				orTableName := ft.Tag.Get("table")
-				if orTableName == "" {
-					panic(errors.New("invalid table name in or tag on field: " + ft.Name))
-				}
-				res = append(res, &orColumn{
-					fieldName: ft.Name,
-					or:        orTag,
-					table:     orTableName,
-					orType:    orType,
-				})
-			} else {
-				panic(errors.New("unsupported or tag: " + orTag + ", only support has_one, has_many and belongs_to for now"))
-			}
-		}
-		if ft.Tag.Get("pk") == "true" {
-			pkColumn = ft
-		}
+// The table of the related model, which is given by tag table, or the table name of the related type
+func (orCol *orColumn) tableName() string {
+	if orCol.table != "" {
+		return orCol.table
 	}
-	return pkColumn, res
+	return getModelInfo(orCol.orType).table
 }
 
 func getTableName(s interface{}) string {
-	ts := reflect.TypeOf(s)
-	if ts.Kind() == reflect.Ptr {
-		ts = ts.Elem()
+	return modelInfoOf(s).table
+}
+
+// The table name of the model type, which is given by TableName() if it implements TableNamer,
+// or converted from the type name by convention
+func getTableNameByType(t reflect.Type) string {
+	if t.Kind() == reflect.Struct && (t.Implements(tableNamerType) || reflect.PtrTo(t).Implements(tableNamerType)) {
+		return reflect.New(t).Interface().(TableNamer).TableName()
 	}
-	return fieldName2ColName(ts.Name())
+	return fieldName2ColName(t.Name())
 }
 
-func selectByPK(tdx Tdx, s interface{}, pk interface{}) error {
-	pkname := getPKColumn(s)
+func selectByPK(tdx Tdx, s interface{}, pks ...interface{}) error {
+	pkCols := getPKColumns(s)
 	tabname := getTableName(s)
-	if pkname == "" {
-		return errors.New(tabname + " does not have primary key")
+	if err := checkPKValues(tabname, pkCols, pks); err != nil {
+		return err
 	}
-	return selectOne(tdx, s, fmt.Sprintf("select * from `%s` where `%s` = ?", tabname, pkname), pk)
+	return selectOne(tdx, s, fmt.Sprintf("select * from `%s` where %s", tabname, whereClause(pkCols)), pks...)
 }
 
 func selectOne(tdx Tdx, s interface{}, query string, args ...interface{}) error {
+	return selectOneWith(tdx, s, nil, query, args...)
+}
+
+// Select one row and load the relations of the preload tree, all the relations without nested ones if it's nil
+func selectOneWith(tdx Tdx, s interface{}, preloads preloadTree, query string, args ...interface{}) error {
 	// One time there only can be one active sql Rows query
 	err := selectOneInternal(tdx, s, query, args...)
 	if err != nil {
 		return err
 	}
-	pk, orColumns := getOrColumns(s)
-	if orColumns != nil && len(orColumns) > 0 {
-		v := reflect.ValueOf(s).Elem()
-		pkValue, err := getFieldValue(s, pk.Name)
-		if err != nil {
-			return err
-		}
-		for _, orCol := range orColumns {
-			if orCol.or == TAG_HAS_ONE {
-				err = processOrHasOneRelation(tdx, orCol, v, pk, pkValue)
-				if err != nil {
-					return err
-				}
-			} else if orCol.or == TAG_HAS_MANY {
-				orField := v.FieldByName(orCol.fieldName)
-				err = selectManyInternal(tdx, orField.Addr().Interface(), false,
-					"SELECT * FROM `"+orCol.table+"` WHERE `"+fieldName2ColName(pk.Name)+"` = ?", pkValue)
-				if err != nil {
-					return err
-				}
-			} else if orCol.or == TAG_BELONGS_TO {
-				fk := getPkColumnByType(orCol.orType)
-				if fk == "" {
-					panic(errors.New("error while getting primary key of " + orCol.table + " for belongs_to"))
-				}
-				fkValue, err := getFieldValue(s, colName2FieldName(fk))
-				if err != nil {
-					return err
-				}
-				err = processOrBelongsToRelation(tdx, orCol, v, fk, fkValue)
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-	return nil
+	return preloadHolder(tdx, s, preloads)
 }
 
 func selectOneInternal(tdx Tdx, s interface{}, query string, args ...interface{}) error {
@@ -325,54 +324,33 @@ func selectOneInternal(tdx Tdx, s interface{}, query string, args ...interface{}
 	return nil
 }
 
-func processOrHasOneRelation(tdx Tdx, orCol *orColumn, v reflect.Value, pk reflect.StructField, pkValue interface{}) error {
-	orRows, err := tdx.Query("SELECT * FROM `"+orCol.table+"` WHERE `"+fieldName2ColName(pk.Name)+"` = ? LIMIT 1",
-		pkValue)
+// Load the relation rows whose cols match any of the keys, and call fn on each of them
+func processOrManyRelation(tdx Tdx, orCol *orColumn, cols []string, keys [][]interface{}, fn func(reflect.Value) error) error {
+	where, args := inClause(cols, keys)
+	orRows, err := tdx.Query("SELECT * FROM `"+orCol.tableName()+"` WHERE "+where, args...)
 	if err != nil {
 		return err
 	}
 	defer orRows.Close()
 
-	if !orRows.Next() {
-		return nil
-	}
 	orCols, err := orRows.Columns()
 	if err != nil {
 		return err
 	}
-	orField := v.FieldByName(orCol.fieldName)
-	orValue := reflect.New(orField.Type().Elem())
-	err = reflectStructValue(orValue, orCols, orRows)
-	if err != nil {
-		return err
-	}
-	orField.Set(orValue)
-	return nil
-}
-
-func processOrBelongsToRelation(tdx Tdx, orCol *orColumn, v reflect.Value, fk string, fkValue interface{}) error {
-	orRows, err := tdx.Query("SELECT * FROM `"+orCol.table+"` WHERE `"+fk+"` = ? LIMIT 1",
-		fkValue)
-	if err != nil {
-		return err
-	}
-	defer orRows.Close()
-
-	if !orRows.Next() {
-		return nil
-	}
-	orCols, err := orRows.Columns()
-	if err != nil {
-		return err
-	}
-	orField := v.FieldByName(orCol.fieldName)
-	orValue := reflect.New(orField.Type().Elem())
-	err = reflectStructValue(orValue, orCols, orRows)
-	if err != nil {
-		return err
+	mi := getModelInfo(orCol.orType)
+	fields := mi.fieldsOf(orCols)
+	for orRows.Next() {
+		orValue := reflect.New(orCol.orType)
+		err = orRows.Scan(scanTargets(orValue.Elem(), fields)...)
+		if err != nil {
+			return err
+		}
+		recordSnapshot(orValue.Elem(), mi)
+		if err := fn(orValue); err != nil {
+			return err
+		}
 	}
-	orField.Set(orValue)
-	return nil
+	return orRows.Err()
 }
 
 func selectStr(tdx Tdx, query string, args ...interface{}) (string, error) {
@@ -461,6 +439,37 @@ func MapScan(r ColScanner, dest map[string]interface{}) error {
 	return r.Err()
 }*/
 
+var stringType = reflect.TypeOf("")
+
+// The string of the raw column value, which is converted by conv if it's registered for string,
+// the same as scanning into a string field. It's not ok for NULL or the unsupported types
+func rawString(conv Converter, column interface{}) (string, bool, error) {
+	if conv != nil {
+		var ret string
+		err := conv.FromColumn(column, reflect.ValueOf(&ret).Elem())
+		return ret, err == nil, err
+	}
+	switch t := column.(type) {
+	case []uint8:
+		return string(t[:]), true, nil
+	case string:
+		return t, true, nil
+	case bool:
+		return strconv.FormatBool(t), true, nil
+	case time.Time:
+		return t.Format("2006-01-02 15:04:05"), true, nil
+	case int64:
+		return strconv.FormatInt(t, 10), true, nil
+	case int:
+		return strconv.Itoa(t), true, nil
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', 4, 32), true, nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', 4, 64), true, nil
+	}
+	return "", false, nil
+}
+
 func selectRawSet(tdx Tdx, query string, args ...interface{}) ([]map[string]string, error) {
 	rows, err := tdx.Query(query, args...)
 	if err != nil {
@@ -469,12 +478,17 @@ func selectRawSet(tdx Tdx, query string, args ...interface{}) ([]map[string]stri
 	defer rows.Close()
 
 	dataSet := make([]map[string]string, 0, 1)
+	cols, err := rows.Columns()
+	if err != nil {
+		return dataSet, err
+	}
+	fnames := make([]string, len(cols))
+	for k, c := range cols {
+		fnames[k] = colName2FieldName(c)
+	}
+	conv := typeConverter(stringType)
 
 	for rows.Next() {
-		cols, err := rows.Columns()
-		if err != nil {
-			return dataSet, err
-		}
 		itemMap := make(map[string]string)
 		itemList := make([]interface{}, len(cols))
 		for i := range itemList {
@@ -487,23 +501,13 @@ func selectRawSet(tdx Tdx, query string, args ...interface{}) ([]map[string]stri
 			log.Println("%v, %v", err, rows)
 			return dataSet, err
 		}
-		for k, c := range cols {
-			fname := colName2FieldName(c)
-			switch t := (*itemList[k].(*interface{})).(type) {
-			case []uint8:
-				itemMap[fname] = string(t[:])
-			case time.Time:
-				itemMap[fname] = t.Format("2006-01-02 15:04:05")
-			case int64:
-				itemMap[fname] = strconv.FormatInt(t, 10)
-			case int:
-				itemMap[fname] = strconv.Itoa(t)
-			case float32:
-				itemMap[fname] = strconv.FormatFloat(float64(t), 'f', 4, 32)
-			case float64:
-				itemMap[fname] = strconv.FormatFloat(t, 'f', 4, 64)
-			case nil:
-			default:
+		for k, fname := range fnames {
+			str, ok, err := rawString(conv, *itemList[k].(*interface{}))
+			if err != nil {
+				return dataSet, err
+			}
+			if ok {
+				itemMap[fname] = str
 			}
 		}
 		dataSet = append(dataSet, itemMap)
@@ -523,6 +527,7 @@ func selectRaw(tdx Tdx, query string, args ...interface{}) ([]string, [][]string
 	if err != nil {
 		return colNames, data, err
 	}
+	conv := typeConverter(stringType)
 
 	for rows.Next() {
 		itemMap := make([]string, len(colNames))
@@ -538,21 +543,9 @@ func selectRaw(tdx Tdx, query string, args ...interface{}) ([]string, [][]string
 			return colNames, data, err
 		}
 		for k, _ := range colNames {
-			switch t := (*itemList[k].(*interface{})).(type) {
-			case []uint8:
-				itemMap[k] = string(t[:])
-			case time.Time:
-				itemMap[k] = t.Format("2006-01-02 15:04:05")
-			case int64:
-				itemMap[k] = strconv.FormatInt(t, 10)
-			case int:
-				itemMap[k] = strconv.Itoa(t)
-			case float32:
-				itemMap[k] = strconv.FormatFloat(float64(t), 'f', 4, 32)
-			case float64:
-				itemMap[k] = strconv.FormatFloat(t, 'f', 4, 64)
-			case nil:
-			default:
+			itemMap[k], _, err = rawString(conv, *itemList[k].(*interface{}))
+			if err != nil {
+				return colNames, data, err
 			}
 		}
 		data = append(data, itemMap)
@@ -561,10 +554,11 @@ func selectRaw(tdx Tdx, query string, args ...interface{}) ([]string, [][]string
 }
 
 func selectMany(tdx Tdx, s interface{}, query string, args ...interface{}) error {
-	return selectManyInternal(tdx, s, true, query, args...)
+	return selectManyInternal(tdx, s, nil, query, args...)
 }
 
-func selectManyInternal(tdx Tdx, s interface{}, processOr bool, query string, args ...interface{}) error {
+// Select the rows and load the relations of the preload tree, all the relations without nested ones if it's nil
+func selectManyInternal(tdx Tdx, s interface{}, preloads preloadTree, query string, args ...interface{}) error {
 	t, err := toSliceType(s)
 	if err != nil {
 		return err
@@ -573,20 +567,15 @@ func selectManyInternal(tdx Tdx, s interface{}, processOr bool, query string, ar
 	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Int64 && t.Kind() != reflect.String &&
 		t.Kind() != reflect.Int && t.Kind() != reflect.Bool && t.Kind() != reflect.Float64 &&
 		t.Kind() != reflect.Float32 && t.Kind() != reflect.Uint64 && t.Kind() != reflect.Uint {
-		return errors.New("slice elements type " + t.Kind().String() + " not supported")
+		return fmt.Errorf("slice elements type %s %w", t.Kind().String(), ErrUnsupportedType)
 	}
 
 	var isPtr = (t.Kind() == reflect.Ptr)
 
-	hasOrCols := false
-	pkCol := reflect.StructField{}
-	var orCols []*orColumn = nil
+	var mi *modelInfo
 	if isPtr {
 		t = t.Elem()
-		if processOr {
-			pkCol, orCols = getOrColumnsByType(t)
-			hasOrCols = orCols != nil && len(orCols) > 0
-		}
+		mi = getModelInfo(t)
 	}
 
 	sliceValue := reflect.Indirect(reflect.ValueOf(s))
@@ -597,42 +586,26 @@ func selectManyInternal(tdx Tdx, s interface{}, processOr bool, query string, ar
 	}
 	defer rows.Close()
 
-	keys := make([]interface{}, 0)
-	resMap := map[interface{}]reflect.Value{}
-	for rows.Next() {
+	var fields []*fieldInfo
+	if isPtr {
 		cols, err := rows.Columns()
 		if err != nil {
 			return err
 		}
+		fields = mi.fieldsOf(cols)
+	}
+
+	for rows.Next() {
 		v := reflect.New(t)
 		if isPtr {
-			targets := make([]interface{}, len(cols))
-			for k, c := range cols {
-				fname := colName2FieldName(c)
-				fv := v.Elem().FieldByName(fname)
-				if !fv.CanAddr() {
-					fmt.Printf("missing field: %s , query: %s\n", fname, query)
-					var b interface{}
-					targets[k] = &b
-					continue
-				}
-				targets[k] = fv.Addr().Interface()
-			}
-			err = rows.Scan(targets...)
+			err = rows.Scan(scanTargets(v.Elem(), fields)...)
 
 			if err != nil {
 				log.Println("%v, %v", err, rows)
 				return err
 			}
+			recordSnapshot(v.Elem(), mi)
 			sliceValue.Set(reflect.Append(sliceValue, v))
-			if hasOrCols {
-				pkFv := v.Elem().FieldByName(pkCol.Name)
-				if pkFv.IsValid() {
-					key := pkFv.Interface()
-					keys = append(keys, key)
-					resMap[key] = v
-				}
-			}
 		} else {
 			err = rows.Scan(v.Interface())
 			if err != nil {
@@ -641,137 +614,36 @@ func selectManyInternal(tdx Tdx, s interface{}, processOr bool, query string, ar
 			sliceValue.Set(reflect.Append(sliceValue, v.Elem()))
 		}
 	}
-	if len(keys) > 0 {
-		for _, orCol := range orCols {
-			var sqlQuery string
-			// 如果是belongs_to，需要先把fk -> array(elem)存下来，然后根据数据库请求结果将对应fk的指针指向相应的关联对象
-			if orCol.or == "belongs_to" {
-				fk := getPkColumnByType(orCol.orType)
-				if fk == "" {
-					return errors.New("error while getting primary key of " + orCol.table + " for belongs_to")
-				}
-				fkCol := colName2FieldName(fk)
-				fkValues := make([]interface{}, 0)
-				fkMaps := map[interface{}][]reflect.Value{}
-				i := 0
-				for _, value := range resMap {
-					fkValue, err := getFieldValue(value.Interface(), fkCol)
-					if err != nil {
-						return err
-					}
-					fkValues = append(fkValues, fkValue)
-					if v, ok := fkMaps[fkValue]; ok {
-						fkMaps[fkValue] = append(v, value)
-					} else {
-						fkMaps[fkValue] = make([]reflect.Value, 0)
-						fkMaps[fkValue] = append(fkMaps[fkValue], value)
-					}
-					i = i + 1
-				}
-				sqlQuery = makeString("SELECT * FROM `"+orCol.table+"` WHERE `"+fk+"` in (",
-					",", ")", fkValues)
-				orRows, err := tdx.Query(sqlQuery)
-
-				if err != nil {
-					return err
-				}
-				defer orRows.Close()
-				for orRows.Next() {
-					orCols, err := orRows.Columns()
-					if err != nil {
-						return err
-					}
-					orValue := reflect.New(orCol.orType)
-					err = reflectStructValue(orValue, orCols, orRows)
-					if err != nil {
-						return err
-					}
-					keyValue := orValue.Elem().FieldByName(fkCol)
-					if keyValue.IsValid() {
-						if arr, ok := fkMaps[keyValue.Interface()]; ok {
-							for _, v := range arr {
-								v.Elem().FieldByName(orCol.fieldName).Set(orValue)
-							}
-						}
-
-					}
-				}
-			} else {
-				sqlQuery = makeString("SELECT * FROM `"+orCol.table+"` WHERE `"+fieldName2ColName(pkCol.Name)+"` in (",
-					",", ")", keys)
-				orRows, err := tdx.Query(sqlQuery)
-
-				if err != nil {
-					return err
-				}
-				defer orRows.Close()
-
-				for orRows.Next() {
-					orCols, err := orRows.Columns()
-					if err != nil {
-						return err
-					}
-					orValue := reflect.New(orCol.orType)
-					err = reflectStructValue(orValue, orCols, orRows)
-					if err != nil {
-						return err
-					}
-					keyValue := orValue.Elem().FieldByName(pkCol.Name)
-					if keyValue.IsValid() {
-						if v, ok := resMap[keyValue.Interface()]; ok {
-							if orCol.or == TAG_HAS_ONE {
-								v.Elem().FieldByName(orCol.fieldName).Set(orValue)
-							} else if orCol.or == TAG_HAS_MANY {
-								orSliceValue := v.Elem().FieldByName(orCol.fieldName)
-								orSliceValue.Set(reflect.Append(orSliceValue, orValue))
-							}
-						}
-					}
-				}
-			}
-		}
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	return nil
-}
-
-func makeString(start, split, end string, ids []interface{}) string {
-	buff := bytes.Buffer{}
-	buff.WriteString(start)
-	len := len(ids)
-	for i, v := range ids {
-		buff.WriteString(fmt.Sprintf("%v", v))
-		if i < len-1 {
-			buff.WriteString(split)
-		}
+	if !isPtr {
+		return nil
 	}
-	buff.WriteString(end)
-	return buff.String()
+	// the rows are closed before loading the relations
+	rows.Close()
+	return preloadHolder(tdx, s, preloads)
 }
 
 func columnsByStruct(s interface{}) (string, string, []interface{}, reflect.Value, bool) {
-	t := reflect.TypeOf(s).Elem()
+	mi := modelInfoOf(s)
 	v := reflect.ValueOf(s).Elem()
 	cols := ""
 	vals := ""
-	ret := make([]interface{}, 0, t.NumField())
+	ret := make([]interface{}, 0, len(mi.fields))
 	n := 0
 	var pk reflect.Value
 	isAi := false
-	for k := 0; k < t.NumField(); k++ {
-		ft := t.Field(k)
-		cn := fieldName2ColName(ft.Name)
-
+	for _, f := range mi.fields {
 		//auto increment field
-		if ft.Tag.Get("pk") == "true" {
-			pk = v.Field(k)
-			if ft.Tag.Get("ai") == "true" {
-				isAi = true
-				continue
-			}
+		if f.ai {
+			pk = v.FieldByIndex(f.index)
+			isAi = true
+			continue
 		}
 
 		//auto update filed, created_at, updated_at, etc.
-		if ft.Tag.Get("ignore") == "true" || ft.Tag.Get("or") != "" {
+		if f.ignore {
 			continue
 		}
 
@@ -780,10 +652,10 @@ func columnsByStruct(s interface{}) (string, string, []interface{}, reflect.Valu
 			vals += ","
 		}
 		cols += "`"
-		cols += cn
+		cols += f.column
 		cols += "`"
 		vals += "?"
-		ret = append(ret, v.Field(k).Addr().Interface())
+		ret = append(ret, fieldArg(v, f))
 		n += 1
 	}
 	return cols, vals, ret, pk, isAi
@@ -791,25 +663,19 @@ func columnsByStruct(s interface{}) (string, string, []interface{}, reflect.Valu
 
 func columnsBySlice(s []interface{}) (string, string, []interface{}, []reflect.Value, []bool) {
 	t := reflect.TypeOf(s[0]).Elem()
-	ret := make([]interface{}, 0, t.NumField()*len(s))
+	mi := getModelInfo(t)
+	ret := make([]interface{}, 0, len(mi.fields)*len(s))
 	cols := "("
 	isFirst := true
-	for k := 0; k < t.NumField(); k++ {
-		ft := t.Field(k)
-		cn := fieldName2ColName(ft.Name)
-		if ft.Tag.Get("pk") == "true" {
-			if ft.Tag.Get("ai") == "true" {
-				continue
-			}
-		}
-		if ft.Tag.Get("ignore") == "true" || ft.Tag.Get("or") != "" {
+	for _, f := range mi.fields {
+		if f.ai || f.ignore {
 			continue
 		}
 		if !isFirst {
 			cols += ","
 		}
 		cols += "`"
-		cols += cn
+		cols += f.column
 		cols += "`"
 		isFirst = false
 	}
@@ -829,20 +695,16 @@ func columnsBySlice(s []interface{}) (string, string, []interface{}, []reflect.V
 		}
 		vals.WriteString("(")
 		isFirst := true
-		for k := 0; k < t.NumField(); k++ {
-			ft := t.Field(k)
-
+		for _, f := range mi.fields {
 			//auto increment field
-			if ft.Tag.Get("pk") == "true" {
-				if ft.Tag.Get("ai") == "true" {
-					pks[n] = v.Field(k)
-					ais[n] = true
-					continue
-				}
+			if f.ai {
+				pks[n] = v.FieldByIndex(f.index)
+				ais[n] = true
+				continue
 			}
 
 			//auto update filed, created_at, updated_at, etc.
-			if ft.Tag.Get("ignore") == "true" || ft.Tag.Get("or") != "" {
+			if f.ignore {
 				continue
 			}
 
@@ -851,7 +713,7 @@ func columnsBySlice(s []interface{}) (string, string, []interface{}, []reflect.V
 			}
 			vals.WriteString("?")
 			isFirst = false
-			ret = append(ret, v.Field(k).Addr().Interface())
+			ret = append(ret, fieldArg(v, f))
 		}
 		vals.WriteString(")")
 	}
@@ -859,11 +721,22 @@ func columnsBySlice(s []interface{}) (string, string, []interface{}, []reflect.V
 	return cols, vals.String(), ret, pks, ais
 }
 
+// Get the auto increment primary key column, empty if there isn't
+func getAiColumnByType(t reflect.Type) string {
+	if ai := getModelInfo(t).ai; ai != nil {
+		return ai.column
+	}
+	return ""
+}
+
 func insert(tdx Tdx, s interface{}) error {
 	cols, vals, ifs, pk, isAi := columnsByStruct(s)
 	t := reflect.TypeOf(s).Elem()
 
-	q := fmt.Sprintf("insert into `%s` (%s) values(%s)", fieldName2ColName(t.Name()), cols, vals)
+	q := fmt.Sprintf("insert into `%s` (%s) values(%s)", getTableName(s), cols, vals)
+	if isAi && dialectOf(tdx).InsertReturning() {
+		return insertReturning(tdx, q+" RETURNING `"+getAiColumnByType(t)+"`", ifs, []reflect.Value{pk})
+	}
 	ret, err := tdx.Exec(q, ifs...)
 	if err != nil {
 		return err
@@ -888,7 +761,10 @@ func insertBatch(tdx Tdx, s []interface{}) error {
 	cols, vals, ifs, pks, ais := columnsBySlice(s)
 	t := reflect.TypeOf(s[0]).Elem()
 
-	q := fmt.Sprintf("insert into `%s` %s values %s", fieldName2ColName(t.Name()), cols, vals)
+	q := fmt.Sprintf("insert into `%s` %s values %s", getTableName(s[0]), cols, vals)
+	if ais[0] && dialectOf(tdx).InsertReturning() {
+		return insertReturning(tdx, q+" RETURNING `"+getAiColumnByType(t)+"`", ifs, pks)
+	}
 	ret, err := tdx.Exec(q, ifs...)
 	if err != nil {
 		return err
@@ -906,6 +782,147 @@ func insertBatch(tdx Tdx, s []interface{}) error {
 	return nil
 }
 
+// Insert with RETURNING the auto increment ids, which are scanned into pks in order
+func insertReturning(tdx Tdx, query string, args []interface{}, pks []reflect.Value) error {
+	rows, err := tdx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for i := 0; rows.Next() && i < len(pks); i++ {
+		if err := rows.Scan(pks[i].Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Build the set clause for update, only the fields in `only` are included unless it's nil
+func columnsForUpdate(s interface{}, only map[string]bool) (string, []interface{}, []string, []interface{}) {
+	mi := modelInfoOf(s)
+	v := reflect.ValueOf(s).Elem()
+	sets := ""
+	ret := make([]interface{}, 0, len(mi.fields))
+	for _, f := range mi.fields {
+		// primary key is used in where clause, and auto update field is never updated
+		if f.pk || f.ignore {
+			continue
+		}
+
+		if only != nil && !only[f.name] {
+			continue
+		}
+
+		if len(ret) > 0 {
+			sets += ","
+		}
+		sets += "`" + f.column + "` = ?"
+		ret = append(ret, fieldArg(v, f))
+	}
+	return sets, ret, mi.pkColumns(), mi.pkValues(v)
+}
+
+func update(tdx Tdx, s interface{}) error {
+	return updateInternal(tdx, s, nil, nil)
+}
+
+func updateByPK(tdx Tdx, s interface{}, pks ...interface{}) error {
+	return updateInternal(tdx, s, pks, nil)
+}
+
+func updateColumns(tdx Tdx, s interface{}, fields ...string) error {
+	mi := modelInfoOf(s)
+	only := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if mi.byName[f] == nil {
+			return &MissingFieldError{Table: getTableName(s), Column: f}
+		}
+		only[f] = true
+	}
+	return updateInternal(tdx, s, nil, only)
+}
+
+// Update only the fields changed since s was loaded, s should have an embedded Snapshot,
+// otherwise all the columns are updated
+func updateChanged(tdx Tdx, s interface{}) error {
+	v := reflect.ValueOf(s).Elem()
+	mi := getModelInfo(v.Type())
+	if mi.snapshot < 0 || v.Field(mi.snapshot).Interface().(Snapshot).values == nil {
+		return updateInternal(tdx, s, nil, nil)
+	}
+	changed := v.Field(mi.snapshot).Interface().(Snapshot).changedFields(v, mi)
+	if len(changed) == 0 {
+		return nil
+	}
+	return updateInternal(tdx, s, nil, changed)
+}
+
+// Update the row identified by pkValues, or by the pk field values of s if pkValues is nil
+func updateInternal(tdx Tdx, s interface{}, pkValues []interface{}, only map[string]bool) error {
+	sets, ifs, pkNames, pks := columnsForUpdate(s, only)
+	tabname := getTableName(s)
+	if pkValues == nil {
+		pkValues = pks
+	}
+	if err := checkPKValues(tabname, pkNames, pkValues); err != nil {
+		return err
+	}
+	if len(ifs) == 0 {
+		return errors.New(tabname + " does not have updatable columns")
+	}
+
+	q := fmt.Sprintf("update `%s` set %s where %s", tabname, sets, whereClause(pkNames))
+	ret, err := tdx.Exec(q, append(ifs, pkValues...)...)
+	if err != nil {
+		return err
+	}
+	ra, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if ra == 0 {
+		// MySQL reports 0 affected rows when the new values equal the old ones,
+		// so double check whether the row exists at all
+		n, err := selectInt(tdx, fmt.Sprintf("select count(*) from `%s` where %s", tabname, whereClause(pkNames)), pkValues...)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return &RowAffectError{Query: q, Expected: 1, Actual: 0}
+		}
+	}
+	// the row of other pk values is updated, which is not the one loaded into s
+	if tupleKey(pkValues) != tupleKey(pks) {
+		return nil
+	}
+	v := reflect.ValueOf(s).Elem()
+	updateSnapshot(v, getModelInfo(v.Type()), only)
+	return nil
+}
+
+func deleteRow(tdx Tdx, s interface{}, expectRows int64) error {
+	mi := modelInfoOf(s)
+	if len(mi.pks) == 0 {
+		return fmt.Errorf("%s %w", getTableName(s), ErrNoPrimaryKey)
+	}
+	return deleteByPK(tdx, s, expectRows, mi.pkValues(reflect.ValueOf(s).Elem())...)
+}
+
+// Delete the row of s's table by primary key, if expectRows is negative then the row affect check is skipped
+func deleteByPK(tdx Tdx, s interface{}, expectRows int64, pks ...interface{}) error {
+	pkCols := getPKColumns(s)
+	tabname := getTableName(s)
+	if err := checkPKValues(tabname, pkCols, pks); err != nil {
+		return err
+	}
+	q := fmt.Sprintf("delete from `%s` where %s", tabname, whereClause(pkCols))
+	if expectRows < 0 {
+		_, err := tdx.Exec(q, pks...)
+		return err
+	}
+	return execWithRowAffectCheck(tdx, expectRows, q, pks...)
+}
+
 func getFieldValue(param interface{}, fieldName string) (interface{}, error) {
 	v := reflect.ValueOf(param)
 	if v.Kind() == reflect.Ptr {
@@ -917,16 +934,16 @@ func getFieldValue(param interface{}, fieldName string) (interface{}, error) {
 		if f.IsValid() {
 			return f.Interface(), nil
 		} else {
-			return nil, errors.New("missing field " + fieldName)
+			return nil, &MissingFieldError{Column: fieldName}
 		}
 	} else if v.Kind() == reflect.Struct {
 		f := v.FieldByName(fieldName)
 		if f.IsValid() {
 			return f.Interface(), nil
 		} else {
-			return nil, errors.New("missing field " + fieldName)
+			return nil, &MissingFieldError{Column: fieldName}
 		}
 	} else {
-		return nil, errors.New(fmt.Sprintf("input interface type {%v} is not supported", v.Kind().String()))
+		return nil, fmt.Errorf("input interface type {%v} is %w", v.Kind().String(), ErrUnsupportedType)
 	}
 }
\ No newline at end of file
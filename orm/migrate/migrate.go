@@ -0,0 +1,328 @@
+// Package migrate applies the versioned schema migrations, which are SQL scripts or Go funcs, in the order of
+// versions, and records the applied ones in the tracking table, i.e. _glorm_migrations which is skipped by the
+// code generator. The SQL migrations are loaded by LoadDir from the files named {version}_{name}.up.sql and
+// {version}_{name}.down.sql, e.g.
+//
+//	0001_create_user.up.sql
+//	0001_create_user.down.sql
+//
+// Each migration and its record are committed in one transaction if the dialect supports transactional DDL,
+// otherwise, i.e. MySQL, the statements are executed one by one, and the migration is recorded after all of
+// them succeed
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zhengyun1112/glorm/orm"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTable tracks the applied migrations, the prefix _ makes the code generator skip it
+const DefaultTable = "_glorm_migrations"
+
+var (
+	// There is no applied migration to be rolled back
+	ErrNoMigration = errors.New("no applied migration")
+	// The migration does not have Down
+	ErrIrreversible = errors.New("irreversible migration")
+)
+
+// Migration is a versioned change of the schema, Down reverts Up and could be nil if it's irreversible
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(orm.ORMer) error
+	Down    func(orm.ORMer) error
+}
+
+func (m *Migration) String() string {
+	return strconv.FormatInt(m.Version, 10) + "_" + m.Name
+}
+
+// Status of a migration, the applied migration which is not found, e.g. its files are removed, is Missing
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool
+}
+
+// record is the row of the tracking table
+type record struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// SQL returns the migration func executing the statements of the script, which are separated by `;`.
+// The statements are written in MySQL style as the queries of orm, see orm.Dialect
+func SQL(script string) func(orm.ORMer) error {
+	stmts := splitStatements(script)
+	return func(o orm.ORMer) error {
+		for _, stmt := range stmts {
+			if _, err := o.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load the SQL migrations from the files of the directory, the other files are ignored
+func LoadDir(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	ret := make([]*Migration, 0)
+	for _, f := range files {
+		matches := fileNamePattern.FindStringSubmatch(f.Name())
+		if f.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of %s: %w", f.Name(), err)
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+			ret = append(ret, m)
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, m.Name, matches[2])
+		}
+		script, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if matches[3] == "up" {
+			m.Up = SQL(string(script))
+		} else {
+			m.Down = SQL(string(script))
+		}
+	}
+	for _, m := range ret {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %s does not have up file", m)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Version < ret[j].Version })
+	return ret, nil
+}
+
+// Migrator applies and rolls back the migrations on the database of orm
+type Migrator struct {
+	// The tracking table, DefaultTable by default
+	Table      string
+	orm        *orm.ORM
+	migrations []*Migration
+}
+
+// New migrator of the migrations, which are sorted by version. The versions should be unique
+func New(o *orm.ORM, migrations []*Migration) (*Migrator, error) {
+	sorted := append([]*Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %s does not have up", m)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+	return &Migrator{Table: DefaultTable, orm: o, migrations: sorted}, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.orm.Exec("CREATE TABLE IF NOT EXISTS `" + m.Table + "` (" +
+		"`version` BIGINT NOT NULL PRIMARY KEY, `name` VARCHAR(255) NOT NULL, `applied_at` TIMESTAMP NOT NULL)")
+	return err
+}
+
+// The applied migrations in the order of versions
+func (m *Migrator) applied() ([]*record, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	records := make([]*record, 0)
+	err := m.orm.Select(&records, "SELECT `version`, `name`, `applied_at` FROM `"+m.Table+"` ORDER BY `version`")
+	return records, err
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	i := sort.Search(len(m.migrations), func(i int) bool { return m.migrations[i].Version >= version })
+	if i < len(m.migrations) && m.migrations[i].Version == version {
+		return m.migrations[i]
+	}
+	return nil
+}
+
+// Status of the applied and pending migrations in the order of versions
+func (m *Migrator) Status() ([]Status, error) {
+	records, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Status, 0, len(m.migrations))
+	byVersion := make(map[int64]*record, len(records))
+	for _, r := range records {
+		byVersion[r.Version] = r
+		if m.find(r.Version) == nil {
+			ret = append(ret, Status{Version: r.Version, Name: r.Name, Applied: true, AppliedAt: r.AppliedAt, Missing: true})
+		}
+	}
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r := byVersion[mig.Version]; r != nil {
+			s.Applied, s.AppliedAt = true, r.AppliedAt
+		}
+		ret = append(ret, s)
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Version < ret[j].Version })
+	return ret, nil
+}
+
+// Apply all the pending migrations in the order of versions, returns the applied ones.
+// It stops at the first failed migration, and the former ones are kept applied
+func (m *Migrator) Up() ([]*Migration, error) {
+	records, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]bool, len(records))
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+	ret := make([]*Migration, 0)
+	for _, mig := range m.migrations {
+		if applied[mig.Version] {
+			continue
+		}
+		if err := m.run(mig, true); err != nil {
+			return ret, err
+		}
+		ret = append(ret, mig)
+	}
+	return ret, nil
+}
+
+// Roll back the last applied migration, returns the rolled back one
+func (m *Migrator) Down() (*Migration, error) {
+	records, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNoMigration
+	}
+	last := records[len(records)-1]
+	mig := m.find(last.Version)
+	if mig == nil {
+		return nil, fmt.Errorf("applied migration %d_%s is not found", last.Version, last.Name)
+	}
+	if mig.Down == nil {
+		return nil, fmt.Errorf("migration %s: %w", mig, ErrIrreversible)
+	}
+	return mig, m.run(mig, false)
+}
+
+// Roll back the last applied migration and apply it again, returns the redone one
+func (m *Migrator) Redo() (*Migration, error) {
+	mig, err := m.Down()
+	if err != nil {
+		return mig, err
+	}
+	return mig, m.run(mig, true)
+}
+
+// Run the migration and record it, in a transaction if the dialect allows
+func (m *Migrator) run(mig *Migration, up bool) error {
+	migrate := func(o orm.ORMer) error {
+		if !up {
+			if err := mig.Down(o); err != nil {
+				return err
+			}
+			_, err := o.Exec("DELETE FROM `"+m.Table+"` WHERE `version` = ?", mig.Version)
+			return err
+		}
+		if err := mig.Up(o); err != nil {
+			return err
+		}
+		_, err := o.Exec("INSERT INTO `"+m.Table+"` (`version`, `name`, `applied_at`) VALUES (?, ?, ?)",
+			mig.Version, mig.Name, time.Now())
+		return err
+	}
+	var err error
+	if m.orm.Dialect().TransactionalDDL() {
+		err = m.orm.DoTransaction(func(tx *orm.ORMTran) error {
+			return migrate(tx)
+		})
+	} else {
+		err = migrate(m.orm)
+	}
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", mig, err)
+	}
+	return nil
+}
+
+// Split the script into statements by `;`, which is not in the quotes or comments. The comments are removed
+func splitStatements(script string) []string {
+	ret := make([]string, 0)
+	var sb strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(sb.String()); stmt != "" {
+			ret = append(ret, stmt)
+		}
+		sb.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// copy until the closing quote, the escaped quote is doubled or after backslash
+			j := i + 1
+			for ; j < len(script) && script[j] != c; j++ {
+				if script[j] == '\\' && c != '`' {
+					j++
+				}
+			}
+			if j >= len(script) {
+				j = len(script) - 1
+			}
+			sb.WriteString(script[i : j+1])
+			i = j
+		case c == '#' || strings.HasPrefix(script[i:], "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			sb.WriteByte('\n')
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			sb.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	flush()
+	return ret
+}
@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"errors"
+	"github.com/zhengyun1112/glorm/orm"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- create the table
+CREATE TABLE test_migrate_a (
+  id BIGINT NOT NULL, # the id
+  note VARCHAR(20) NOT NULL DEFAULT 'a;b' /* ; */
+);
+INSERT INTO test_migrate_a VALUES (1, 'it''s;'), (2, "\";");;
+`
+	stmts := splitStatements(script)
+	if len(stmts) != 2 {
+		t.Fatalf("Expected 2 statements, but got %d: %q", len(stmts), stmts)
+	}
+	if stmts[1] != `INSERT INTO test_migrate_a VALUES (1, 'it''s;'), (2, "\";")` {
+		t.Errorf("Unexpected statement: %s", stmts[1])
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"0002_add_name.up.sql":     "ALTER TABLE test_migrate_a ADD name VARCHAR(20)",
+		"0001_create_a.up.sql":     "CREATE TABLE test_migrate_a (id BIGINT NOT NULL PRIMARY KEY)",
+		"0001_create_a.down.sql":   "DROP TABLE test_migrate_a",
+		"README.md":                "ignored",
+		"0003_broken.down.sql":     "DROP TABLE test_migrate_b",
+		"0010_create_c.up.sql.bak": "ignored",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadDir(dir); err == nil {
+		t.Error("Expected error of the migration without up file")
+	}
+	os.Remove(filepath.Join(dir, "0003_broken.down.sql"))
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(migrations))
+	for i, m := range migrations {
+		names[i] = m.String()
+	}
+	if !reflect.DeepEqual(names, []string{"1_create_a", "2_add_name"}) {
+		t.Errorf("Unexpected migrations: %v", names)
+	}
+	if migrations[0].Down == nil || migrations[1].Down != nil {
+		t.Error("Unexpected down of migrations")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "01_create_b.up.sql"), []byte("SELECT 1"), 0644)
+	if _, err := LoadDir(dir); err == nil {
+		t.Error("Expected error of the duplicate version")
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	m := orm.NewORM()
+	m.Init("root:@/test?parseTime=true&loc=Local", 10, 5)
+	defer m.Exec("DROP TABLE IF EXISTS test_migrate_a, _test_migrations")
+
+	migrations := []*Migration{
+		{
+			Version: 2,
+			Name:    "add_name",
+			Up:      SQL("ALTER TABLE test_migrate_a ADD name VARCHAR(20) NOT NULL DEFAULT ''"),
+			Down:    SQL("ALTER TABLE test_migrate_a DROP name"),
+		},
+		{
+			Version: 1,
+			Name:    "create_a",
+			Up:      SQL("CREATE TABLE test_migrate_a (id BIGINT NOT NULL PRIMARY KEY); INSERT INTO test_migrate_a VALUES (1)"),
+			Down:    SQL("DROP TABLE test_migrate_a"),
+		},
+	}
+	migrator, err := New(m, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator.Table = "_test_migrations"
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != 2 || applied[0].Version != 1 {
+		t.Fatalf("Up failed: %v, %v", applied, err)
+	}
+	if name, err := m.SelectStr("SELECT name FROM test_migrate_a WHERE id = 1"); err != nil || name != "" {
+		t.Errorf("Unexpected name %q, %v", name, err)
+	}
+	if applied, err := migrator.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("Expected no pending migration, but got %v, %v", applied, err)
+	}
+
+	mig, err := migrator.Redo()
+	if err != nil || mig.Version != 2 {
+		t.Fatalf("Redo failed: %v, %v", mig, err)
+	}
+	mig, err = migrator.Down()
+	if err != nil || mig.Version != 2 {
+		t.Fatalf("Down failed: %v, %v", mig, err)
+	}
+	if _, err := m.SelectStr("SELECT name FROM test_migrate_a WHERE id = 1"); err == nil {
+		t.Error("Expected the column name is dropped")
+	}
+	status, err := migrator.Status()
+	if err != nil || len(status) != 2 || !status[0].Applied || status[1].Applied {
+		t.Errorf("Unexpected status: %v, %v", status, err)
+	}
+
+	// the failed migration is not recorded
+	failed, _ := New(m, append(migrations, &Migration{Version: 3, Name: "failed", Up: SQL("SELECT * FROM test_migrate_none")}))
+	failed.Table = "_test_migrations"
+	if applied, err := failed.Up(); err == nil || len(applied) != 1 {
+		t.Errorf("Expected the failed migration, but got %v, %v", applied, err)
+	}
+	if n, err := m.SelectInt("SELECT COUNT(*) FROM _test_migrations"); err != nil || n != 2 {
+		t.Errorf("Expected 2 applied migrations, but got %d, %v", n, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := migrator.Down(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := migrator.Down(); !errors.Is(err, ErrNoMigration) {
+		t.Errorf("Expected ErrNoMigration, but got %v", err)
+	}
+}
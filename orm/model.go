@@ -0,0 +1,332 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fieldInfo maps a struct field to a column, the field could be promoted from an embedded struct,
+// or in a group of columns, whose name is the path like Home.City
+type fieldInfo struct {
+	name   string
+	column string
+	index  []int
+	pk     bool
+	ai     bool
+	// auto update column, i.e. created_at, which is selected but never written
+	ignore bool
+	// converter of the field, nil if the field is supported by the driver
+	conv Converter
+}
+
+// modelInfo is the reflection metadata of a model type, it's computed once and cached by getModelInfo
+type modelInfo struct {
+	typ   reflect.Type
+	table string
+	// column fields in the order of struct fields, relation fields and Snapshot are excluded
+	fields    []*fieldInfo
+	byName    map[string]*fieldInfo
+	byColumn  map[string]*fieldInfo
+	pks       []*fieldInfo
+	ai        *fieldInfo
+	relations []*orColumn
+	// index of the embedded Snapshot, -1 if it's not tracked
+	snapshot int
+	// column name -> *fieldInfo of the selected columns, nil if there is no field for the column
+	columns sync.Map
+}
+
+// TableNamer overrides the table name of a model, i.e. a table with prefix
+//
+//	func (a Article) TableName() string {
+//		return "t_article"
+//	}
+//
+// By default the table name is converted from the type name, e.g. ArticleTag -> article_tag
+type TableNamer interface {
+	TableName() string
+}
+
+var tableNamerType = reflect.TypeOf((*TableNamer)(nil)).Elem()
+
+// Timestamps are the auto update columns shared by models, embed it into the model struct, i.e.
+//
+//	type Article struct {
+//		orm.Timestamps
+//		ArticleId int64 `pk:"true" ai:"true"`
+//	}
+type Timestamps struct {
+	CreatedAt time.Time `ignore:"true"`
+	UpdatedAt time.Time `ignore:"true"`
+}
+
+var modelInfos sync.Map
+
+func getModelInfo(t reflect.Type) *modelInfo {
+	if mi, ok := modelInfos.Load(t); ok {
+		return mi.(*modelInfo)
+	}
+	mi, _ := modelInfos.LoadOrStore(t, newModelInfo(t))
+	return mi.(*modelInfo)
+}
+
+// The model info of s, which could be T, *T or *[]*T
+func modelInfoOf(s interface{}) *modelInfo {
+	return getModelInfo(holderType(s))
+}
+
+func newModelInfo(t reflect.Type) *modelInfo {
+	mi := &modelInfo{
+		typ:       t,
+		table:     getTableNameByType(t),
+		byName:    make(map[string]*fieldInfo, t.NumField()),
+		byColumn:  make(map[string]*fieldInfo, t.NumField()),
+		relations: make([]*orColumn, 0),
+		snapshot:  snapshotFieldIndex(t),
+	}
+	mi.addFields(t, nil, "", "")
+	// the field of outer struct hides the promoted one with same name
+	fields := mi.fields
+	mi.fields = make([]*fieldInfo, 0, len(fields))
+	for _, f := range fields {
+		if mi.byName[f.name] != f {
+			continue
+		}
+		mi.fields = append(mi.fields, f)
+		mi.byColumn[f.column] = f
+		if f.pk {
+			mi.pks = append(mi.pks, f)
+		}
+		if f.ai && mi.ai == nil {
+			mi.ai = f
+		}
+	}
+	return mi
+}
+
+// Add the fields of struct t, the fields of embedded structs are promoted, and the fields of the struct
+// with prefix tag are grouped, i.e. Home Address `prefix:"home_"` maps Home.City to column home_city.
+// The embedded pointer of struct, e.g. *Timestamps, is skipped since it may be nil when scanning and writing
+func (mi *modelInfo) addFields(t reflect.Type, index []int, path string, prefix string) {
+	for k := 0; k < t.NumField(); k++ {
+		ft := t.Field(k)
+		fieldIndex := append(append(make([]int, 0, len(index)+1), index...), k)
+		if ft.Tag.Get("or") != "" {
+			if path == "" {
+				mi.relations = append(mi.relations, newOrColumn(ft))
+			}
+			continue
+		}
+		if ft.Type == snapshotType || ft.Tag.Get("db") == "-" {
+			continue
+		}
+		conv := fieldConverter(ft)
+		if ft.Anonymous && ft.Type.Kind() == reflect.Struct && conv == nil {
+			mi.addFields(ft.Type, fieldIndex, path, prefix)
+			continue
+		}
+		if ft.Anonymous && ft.Type.Kind() == reflect.Ptr && ft.Type.Elem().Kind() == reflect.Struct && conv == nil {
+			if ft.PkgPath == "" {
+				log.Println("embedded pointer is not supported, the fields are skipped", mi.typ.Name(), ft.Name)
+			}
+			continue
+		}
+		if ft.PkgPath != "" {
+			continue
+		}
+		if groupPrefix, ok := ft.Tag.Lookup("prefix"); ok && ft.Type.Kind() == reflect.Struct && conv == nil {
+			mi.addFields(ft.Type, fieldIndex, path+ft.Name+".", prefix+groupPrefix)
+			continue
+		}
+		f := &fieldInfo{
+			name:   path + ft.Name,
+			column: prefix + columnName(ft),
+			index:  fieldIndex,
+			pk:     ft.Tag.Get("pk") == "true",
+			ignore: ft.Tag.Get("ignore") == "true",
+			conv:   conv,
+		}
+		f.ai = f.pk && ft.Tag.Get("ai") == "true"
+		mi.fields = append(mi.fields, f)
+		if old := mi.byName[f.name]; old == nil || len(old.index) > len(f.index) {
+			mi.byName[f.name] = f
+		}
+	}
+}
+
+// The column name of the field, which is given by the db tag, or converted from the field name by convention
+func columnName(ft reflect.StructField) string {
+	if col := ft.Tag.Get("db"); col != "" {
+		return col
+	}
+	return fieldName2ColName(ft.Name)
+}
+
+// The relation of the field, the field of has_one and belongs_to must be pointer of struct, and has_many must be
+// slice of pointer of struct. The error of invalid relation is returned by AddTable and the queries loading it
+func newOrColumn(ft reflect.StructField) *orColumn {
+	orCol := &orColumn{
+		fieldName: ft.Name,
+		or:        ft.Tag.Get("or"),
+		table:     ft.Tag.Get("table"),
+		fk:        tagColumns(ft, "fk"),
+		ref:       tagColumns(ft, "ref"),
+	}
+	switch orCol.or {
+	case TAG_HAS_ONE, TAG_BELONGS_TO:
+		if ft.Type.Kind() == reflect.Ptr {
+			orCol.orType = ft.Type.Elem()
+		}
+	case TAG_HAS_MANY:
+		if ft.Type.Kind() == reflect.Slice && ft.Type.Elem().Kind() == reflect.Ptr {
+			orCol.orType = ft.Type.Elem().Elem()
+		}
+	default:
+		orCol.err = errors.New("unsupported or tag " + orCol.or + ", only has_one, has_many and belongs_to are supported")
+		return orCol
+	}
+	if orCol.orType == nil || orCol.orType.Kind() != reflect.Struct {
+		if orCol.or == TAG_HAS_MANY {
+			orCol.err = errors.New("field should be slice of pointer of struct")
+		} else {
+			orCol.err = errors.New("field should be pointer of struct")
+		}
+	}
+	return orCol
+}
+
+// The columns of the tag separated by comma, i.e. `fk:"author_id"`, nil if the tag is not given
+func tagColumns(ft reflect.StructField, tag string) []string {
+	value := ft.Tag.Get(tag)
+	if value == "" {
+		return nil
+	}
+	cols := strings.Split(value, ",")
+	for i, c := range cols {
+		cols[i] = strings.TrimSpace(c)
+	}
+	return cols
+}
+
+// Check the key columns of all the relations have the fields on the model and the related model
+func (mi *modelInfo) checkRelations() error {
+	for _, orCol := range mi.relations {
+		cols, refCols, err := orCol.keyColumns(mi)
+		if err != nil {
+			return err
+		}
+		orMi := getModelInfo(orCol.orType)
+		for i := range cols {
+			if mi.fieldOf(cols[i]) == nil {
+				return fmt.Errorf("relation %s of %s: %w", orCol.fieldName, mi.typ.Name(), &MissingFieldError{Table: mi.table, Column: cols[i]})
+			}
+			if orMi.fieldOf(refCols[i]) == nil {
+				return fmt.Errorf("relation %s of %s: %w", orCol.fieldName, mi.typ.Name(), &MissingFieldError{Table: orMi.table, Column: refCols[i]})
+			}
+		}
+	}
+	return nil
+}
+
+// Look up the field of the column, the fields without db tag are also matched by the converted field name
+func (mi *modelInfo) fieldOf(column string) *fieldInfo {
+	if f := mi.byColumn[column]; f != nil {
+		return f
+	}
+	f := mi.byName[colName2FieldName(column)]
+	if f == nil || f.column != fieldName2ColName(f.name) {
+		return nil
+	}
+	return f
+}
+
+// The field of the selected column, nil if there isn't
+func (mi *modelInfo) field(column string) *fieldInfo {
+	if f, ok := mi.columns.Load(column); ok {
+		return f.(*fieldInfo)
+	}
+	f := mi.fieldOf(column)
+	if f == nil {
+		log.Println("missing field", mi.typ.Name(), column)
+	}
+	mi.columns.Store(column, f)
+	return f
+}
+
+// The fields of the selected columns, which are looked up once for all the rows
+func (mi *modelInfo) fieldsOf(cols []string) []*fieldInfo {
+	ret := make([]*fieldInfo, len(cols))
+	for k, c := range cols {
+		ret[k] = mi.field(c)
+	}
+	return ret
+}
+
+// The scan targets of v's fields, the column without field is scanned and discarded
+func scanTargets(v reflect.Value, fields []*fieldInfo) []interface{} {
+	targets := make([]interface{}, len(fields))
+	for k, f := range fields {
+		if f == nil {
+			targets[k] = new(interface{})
+		} else if f.conv != nil {
+			targets[k] = convField{conv: f.conv, field: v.FieldByIndex(f.index)}
+		} else {
+			targets[k] = v.FieldByIndex(f.index).Addr().Interface()
+		}
+	}
+	return targets
+}
+
+// The arg of v's field to be written, which is converted by the converter of the field if there is
+func fieldArg(v reflect.Value, f *fieldInfo) interface{} {
+	if f.conv != nil {
+		return convField{conv: f.conv, field: v.FieldByIndex(f.index)}
+	}
+	return v.FieldByIndex(f.index).Addr().Interface()
+}
+
+func (mi *modelInfo) pkColumns() []string {
+	ret := make([]string, len(mi.pks))
+	for i, f := range mi.pks {
+		ret[i] = f.column
+	}
+	return ret
+}
+
+func (mi *modelInfo) pkValues(v reflect.Value) []interface{} {
+	ret := make([]interface{}, len(mi.pks))
+	for i, f := range mi.pks {
+		ret[i] = columnValue(v.FieldByIndex(f.index))
+	}
+	return ret
+}
+
+// The values of v's fields of the columns, a MissingFieldError is returned if any column has no field
+func (mi *modelInfo) columnValues(v reflect.Value, cols []string) ([]interface{}, error) {
+	ret := make([]interface{}, len(cols))
+	for i, c := range cols {
+		f := mi.fieldOf(c)
+		if f == nil {
+			return nil, &MissingFieldError{Table: mi.table, Column: c}
+		}
+		ret[i] = columnValue(v.FieldByIndex(f.index))
+	}
+	return ret, nil
+}
+
+// The value of the column field, the pointer field of nullable column is dereferenced and nil for NULL,
+// so that the values are comparable as relation keys
+func columnValue(fv reflect.Value) interface{} {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	return fv.Interface()
+}
@@ -1,19 +1,42 @@
 // ORM framework for MySQL. Using tag & reflection to map SQL query result to struct
-// currently ONLY SUPPORT MYSQL!!
+// MySQL is the default dialect, PostgreSQL and SQLite are supported by NewORMWithDialect, whose drivers
+// should be imported by the user. The queries are always written in MySQL style, see Dialect
 // Some conventions:
-// 1. Each of the table column(under_score) maps to struct field(CamelCase);
+// 1. Each of the table column(under_score) maps to struct field(CamelCase), unless the field has tag
+//      `db:"column_name"`. Tag the field with `db:"-"` if it's not a column;
 // 2. For the primary key, should specify the struct field with tag `pk:"true"`. And if it's auto increment,
-//      then add tag `ai:"true"`
+//      then add tag `ai:"true"`. For composite primary key, tag all the key fields and pass the key values
+//      in the order of struct fields, e.g. SelectByPK(&obj, userId, articleId)
 // 3. It's a good practice to have only one ORM instance globally, otherwise there will be several side effects,
 //      such as the db connection will be exhausted
+// 4. The table name is converted from the struct name(CamelCase), unless the struct implements TableNamer
+// 5. The fields of embedded structs are mapped as the fields of the model, e.g. Timestamps. And the fields of
+//      a struct field with tag `prefix:"home_"` are mapped to the columns with the prefix, e.g. home_city
+// 6. For nullable column, use pointer field, e.g. *string, or sql.Null* field. NULL is scanned into nil pointer,
+//      and nil pointer is written as NULL
+// 7. The field of type not supported by the driver is converted by the Converter registered for its type, or for
+//      its tag, e.g. `conv:"json"` marshals the field of struct, map, etc. as json. The field of json.RawMessage
+//      is stored as is
+// 8. The indexes are declared by tag `index:"idx_name"` or `unique:"uk_name"`, the fields with the same index name
+//      make a composite index in the order of fields, and `unique:"true"` is the index named after the column.
+//      The column type is derived from the field type, which could be sized by tag `size:"64"`, e.g. varchar(64),
+//      or `size:"10,2"` for decimal(10,2) of float, and the default value is given by tag `default:"0"`, e.g. true for bool.
+//      The tables are created by CreateTables, and compared with the database by DiffTables
+// 9. The relations are tagged by `or:"has_one"`, `or:"has_many"` or `or:"belongs_to"`, which are loaded by
+//      one IN query per relation after the select. Only the direct relations are loaded by default, the nested
+//      ones are loaded by Preload, e.g. Query(&articles).Preload("Comments.User"), and Preload() skips all of them.
+//      The relation is joined by the primary key columns of the referenced model by default, otherwise the
+//      columns are given by tag `fk:"author_id"` and `ref:"user_id"`, which are checked by AddTable.
+//      The related table is the table of the related model, or given by tag `table:"user"`
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	_ "github.com/go-sql-driver/mysql"
 	"log"
-	"reflect"
-	"strings"
+	"sort"
 )
 
 const (
@@ -23,27 +46,64 @@ const (
 )
 
 var Default *ORM = &ORM{
-	db:     nil,
-	tables: make(map[string]interface{}),
+	db:      nil,
+	tables:  make(map[string]interface{}),
+	dialect: MySQL,
 }
 
 type ORMer interface {
 	SelectOne(interface{}, string, ...interface{}) error
-	SelectByPK(interface{}, interface{}) error
+	SelectByPK(interface{}, ...interface{}) error
 	Select(interface{}, string, ...interface{}) error
 	SelectStr(string, ...interface{}) (string, error)
 	SelectInt(string, ...interface{}) (int64, error)
 	SelectFloat64(string, ...interface{}) (float64, error)
+	Query(interface{}) *QueryBuilder
 	Insert(interface{}) error
 	InsertBatch([]interface{}) error
+	Update(interface{}) error
+	UpdateByPK(interface{}, ...interface{}) error
+	UpdateColumns(interface{}, ...string) error
+	UpdateChanged(interface{}) error
+	Delete(interface{}) error
+	DeleteByPK(interface{}, ...interface{}) error
 	Exec(string, ...interface{}) (sql.Result, error)
 	ExecWithParam(string, interface{}) (sql.Result, error)
 	ExecWithRowAffectCheck(int64, string, ...interface{}) error
+	SelectOneContext(context.Context, interface{}, string, ...interface{}) error
+	SelectByPKContext(context.Context, interface{}, ...interface{}) error
+	SelectContext(context.Context, interface{}, string, ...interface{}) error
+	SelectStrContext(context.Context, string, ...interface{}) (string, error)
+	SelectIntContext(context.Context, string, ...interface{}) (int64, error)
+	SelectFloat64Context(context.Context, string, ...interface{}) (float64, error)
+	QueryContext(context.Context, interface{}) *QueryBuilder
+	Preload(interface{}, ...string) error
+	PreloadContext(context.Context, interface{}, ...string) error
+	InsertContext(context.Context, interface{}) error
+	InsertBatchContext(context.Context, []interface{}) error
+	UpdateContext(context.Context, interface{}) error
+	UpdateByPKContext(context.Context, interface{}, ...interface{}) error
+	UpdateColumnsContext(context.Context, interface{}, ...string) error
+	UpdateChangedContext(context.Context, interface{}) error
+	DeleteContext(context.Context, interface{}) error
+	DeleteByPKContext(context.Context, interface{}, ...interface{}) error
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	ExecWithParamContext(context.Context, string, interface{}) (sql.Result, error)
+	ExecWithRowAffectCheckContext(context.Context, int64, string, ...interface{}) error
 }
 
 type ORM struct {
-	db     *sql.DB
-	tables map[string]interface{}
+	db      *sql.DB
+	tables  map[string]interface{}
+	dialect Dialect
+}
+
+// Options to open the ORM, the zero value of each field means the default, i.e. MySQL,
+// 10 max open connections and 5 max idle connections
+type Options struct {
+	Dialect      Dialect
+	MaxOpenConns int
+	MaxIdleConns int
 }
 
 func InitDefault(ds string) {
@@ -54,10 +114,24 @@ func InitDefaultWithConnNum(ds string, maxConnNum int, minConnNum int) {
 	Default.Init(ds, maxConnNum, minConnNum)
 }
 
+// Same as InitDefault, but returns the error instead of exiting, and the db is pinged
+func InitDefaultWithOptions(ds string, opts Options) error {
+	if opts.Dialect != nil {
+		Default.dialect = opts.Dialect
+	}
+	return Default.open(ds, opts)
+}
+
 func NewORM() *ORM {
+	return NewORMWithDialect(MySQL)
+}
+
+// New ORM for the database of the dialect, which should be initialized by Init later
+func NewORMWithDialect(dialect Dialect) *ORM {
 	return &ORM{
-		db:     nil,
-		tables: make(map[string]interface{}),
+		db:      nil,
+		tables:  make(map[string]interface{}),
+		dialect: dialect,
 	}
 }
 
@@ -67,9 +141,23 @@ func NewORMWithConnNum(ds string, maxConnNum int, minConnNum int) *ORM {
 	return ret
 }
 
+// Open the ORM with the default options, an error is returned if the db can not be connected
+func Open(ds string) (*ORM, error) {
+	return NewORMWithOptions(ds, Options{})
+}
+
+// Open the ORM with the options, an error is returned if the db can not be connected
+func NewORMWithOptions(ds string, opts Options) (*ORM, error) {
+	ret := NewORMWithDialect(opts.Dialect)
+	if err := ret.open(ds, opts); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
 func (o *ORM) Init(ds string, maxConnNum int, minConnNum int) {
 	var err error
-	o.db, err = sql.Open("mysql", ds)
+	o.db, err = sql.Open(o.Dialect().DriverName(), ds)
 	if err != nil {
 		log.Fatalln("can not connect to db:", err)
 	}
@@ -77,25 +165,101 @@ func (o *ORM) Init(ds string, maxConnNum int, minConnNum int) {
 	o.db.SetMaxIdleConns(minConnNum)
 }
 
+func (o *ORM) open(ds string, opts Options) error {
+	if opts.MaxOpenConns <= 0 {
+		opts.MaxOpenConns = 10
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 5
+	}
+	db, err := sql.Open(o.Dialect().DriverName(), ds)
+	if err != nil {
+		return errors.New("can not connect to db: " + err.Error())
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return errors.New("can not connect to db: " + err.Error())
+	}
+	o.db = db
+	return nil
+}
+
+func (o *ORM) Dialect() Dialect {
+	if o.dialect == nil {
+		return MySQL
+	}
+	return o.dialect
+}
+
+func (o *ORM) session(ctx context.Context) Tdx {
+	return newSession(o.db, ctx, o.Dialect())
+}
+
 func (o *ORM) Close() error {
 	return o.db.Close()
 }
 
 // Register the table into ORM object, so that you can check whether the struct fields
 // match the columns in db. If you don't register table, then you'll lose the functionality
-// of CheckTables/GetTableByName/TruncateTables, which is OK since it's not key functions
-func (o *ORM) AddTable(s interface{}) {
-	name := fieldName2ColName(reflect.TypeOf(s).Name())
-	o.tables[name] = s
+// of CheckTables/GetTableByName/TruncateTables, which is OK since it's not key functions.
+// The error is returned if any relation is invalid, e.g. the key columns have no fields, see keyColumns
+func (o *ORM) AddTable(s interface{}) error {
+	if err := modelInfoOf(s).checkRelations(); err != nil {
+		return err
+	}
+	o.tables[getTableName(s)] = s
+	return nil
 }
 
-func (o *ORM) CheckTables() {
-	for _, s := range o.tables {
-		err := checkTableColumns(o.db, s)
-		if err != nil {
-			log.Fatalln("can not pass table check:", err)
+// Check all the registered tables, the returned TableCheckError lists every mismatched table and column
+func (o *ORM) CheckTables() error {
+	names := make([]string, 0, len(o.tables))
+	for name, _ := range o.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]error, 0)
+	for _, name := range names {
+		errs = append(errs, checkTableColumns(o.session(context.Background()), o.tables[name])...)
+	}
+	if len(errs) > 0 {
+		return &TableCheckError{Errors: errs}
+	}
+	return nil
+}
+
+// Create the table of the model if it doesn't exist, the columns are derived from the fields by the convention 8.
+// Only MySQL is supported for now
+func (o *ORM) CreateTable(s interface{}) error {
+	return createTable(o.session(context.Background()), s)
+}
+
+// Create the tables of all the registered models if they don't exist
+func (o *ORM) CreateTables() error {
+	names := make([]string, 0, len(o.tables))
+	for name := range o.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := o.CreateTable(o.tables[name]); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// Compare the registered models with their tables in database, returns the mismatched ones, whose Statements
+// converge the tables to the models. Only MySQL is supported for now
+func (o *ORM) DiffTables() ([]*TableDiff, error) {
+	return diffTableModels(o.session(context.Background()), o.tables)
+}
+
+// Compare the model with its table in database, see DiffTables
+func (o *ORM) DiffTable(s interface{}) (*TableDiff, error) {
+	return diffTableModel(o.session(context.Background()), s)
 }
 
 func (o *ORM) GetTableByName(name string) interface{} {
@@ -108,7 +272,7 @@ func (o *ORM) GetTableByName(name string) interface{} {
 }
 
 func (o *ORM) TruncateTable(t string) error {
-	_, err := o.db.Exec("truncate table " + t)
+	_, err := o.session(context.Background()).Exec(o.Dialect().TruncateTable(t))
 	return err
 }
 
@@ -123,64 +287,222 @@ func (o *ORM) TruncateTables() error {
 }
 
 func (o *ORM) Begin() (*ORMTran, error) {
-	tx, err := o.db.Begin()
-	return &ORMTran{tx: tx}, err
+	return o.BeginTx(context.Background(), nil)
+}
+
+// Begin a transaction with the context and options, the context is used until the transaction is
+// committed or rolled back, and the transaction is rolled back if the context is done before that
+func (o *ORM) BeginTx(ctx context.Context, opts *sql.TxOptions) (*ORMTran, error) {
+	tx, err := o.db.BeginTx(ctx, opts)
+	return &ORMTran{tx: tx, dialect: o.Dialect()}, err
 }
 
 func (o *ORM) SelectOne(s interface{}, query string, args ...interface{}) error {
-	return selectOne(o.db, s, query, args...)
+	return o.SelectOneContext(context.Background(), s, query, args...)
+}
+
+func (o *ORM) SelectOneContext(ctx context.Context, s interface{}, query string, args ...interface{}) error {
+	return selectOne(o.session(ctx), s, query, args...)
+}
+
+func (o *ORM) SelectByPK(s interface{}, pks ...interface{}) error {
+	return o.SelectByPKContext(context.Background(), s, pks...)
 }
 
-func (o *ORM) SelectByPK(s interface{}, pk interface{}) error {
-	return selectByPK(o.db, s, pk)
+func (o *ORM) SelectByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return selectByPK(o.session(ctx), s, pks...)
 }
 
 func (o *ORM) Select(s interface{}, query string, args ...interface{}) error {
-	return selectMany(o.db, s, query, args...)
+	return o.SelectContext(context.Background(), s, query, args...)
+}
+
+func (o *ORM) SelectContext(ctx context.Context, s interface{}, query string, args ...interface{}) error {
+	return selectMany(o.session(ctx), s, query, args...)
 }
 
 func (o *ORM) SelectRawSet(query string, args ...interface{}) ([]map[string]string, error) {
-	return selectRawSet(o.db, query, args...)
+	return o.SelectRawSetContext(context.Background(), query, args...)
+}
+
+func (o *ORM) SelectRawSetContext(ctx context.Context, query string, args ...interface{}) ([]map[string]string, error) {
+	return selectRawSet(o.session(ctx), query, args...)
 }
 
 func (o *ORM) SelectRaw(query string, args ...interface{}) ([]string, [][]string, error) {
-	return selectRaw(o.db, query, args...)
+	return o.SelectRawContext(context.Background(), query, args...)
+}
+
+func (o *ORM) SelectRawContext(ctx context.Context, query string, args ...interface{}) ([]string, [][]string, error) {
+	return selectRaw(o.session(ctx), query, args...)
 }
 
 func (o *ORM) SelectStr(query string, args ...interface{}) (string, error) {
-	return selectStr(o.db, query, args...)
+	return o.SelectStrContext(context.Background(), query, args...)
+}
+
+func (o *ORM) SelectStrContext(ctx context.Context, query string, args ...interface{}) (string, error) {
+	return selectStr(o.session(ctx), query, args...)
 }
 
 func (o *ORM) SelectInt(query string, args ...interface{}) (int64, error) {
-	return selectInt(o.db, query, args...)
+	return o.SelectIntContext(context.Background(), query, args...)
+}
+
+func (o *ORM) SelectIntContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return selectInt(o.session(ctx), query, args...)
 }
 
 func (o *ORM) SelectFloat64(query string, args ...interface{}) (float64, error) {
-	return selectFloat64(o.db, query, args...)
+	return o.SelectFloat64Context(context.Background(), query, args...)
+}
+
+func (o *ORM) SelectFloat64Context(ctx context.Context, query string, args ...interface{}) (float64, error) {
+	return selectFloat64(o.session(ctx), query, args...)
+}
+
+// Start a query builder on the table of s, which is the holder of the result,
+// i.e. a pointer of struct for One or a pointer of slice for All
+func (o *ORM) Query(s interface{}) *QueryBuilder {
+	return o.QueryContext(context.Background(), s)
+}
+
+func (o *ORM) QueryContext(ctx context.Context, s interface{}) *QueryBuilder {
+	return newQueryBuilder(o.session(ctx), s)
+}
+
+// Load the relations of the paths into the selected models, e.g. Preload(&articles, "Comments.User"),
+// see QueryBuilder.Preload
+func (o *ORM) Preload(s interface{}, paths ...string) error {
+	return o.PreloadContext(context.Background(), s, paths...)
+}
+
+func (o *ORM) PreloadContext(ctx context.Context, s interface{}, paths ...string) error {
+	return preloadHolder(o.session(ctx), s, newPreloadTree(paths))
 }
 
 func (o *ORM) Insert(s interface{}) error {
-	return insert(o.db, s)
+	return o.InsertContext(context.Background(), s)
+}
+
+func (o *ORM) InsertContext(ctx context.Context, s interface{}) error {
+	return insert(o.session(ctx), s)
 }
 
 func (o *ORM) InsertBatch(s []interface{}) error {
-	return insertBatch(o.db, s)
+	return o.InsertBatchContext(context.Background(), s)
+}
+
+func (o *ORM) InsertBatchContext(ctx context.Context, s []interface{}) error {
+	return insertBatch(o.session(ctx), s)
+}
+
+// Update all the columns of the row identified by the `pk:"true"` field, fields tagged with
+// `ignore:"true"` or `or:"..."` are skipped. A RowAffectError is returned if no row matched
+func (o *ORM) Update(s interface{}) error {
+	return o.UpdateContext(context.Background(), s)
+}
+
+func (o *ORM) UpdateContext(ctx context.Context, s interface{}) error {
+	return update(o.session(ctx), s)
+}
+
+// Same as Update, but the row is identified by the given pk values instead of the pk field values
+func (o *ORM) UpdateByPK(s interface{}, pks ...interface{}) error {
+	return o.UpdateByPKContext(context.Background(), s, pks...)
+}
+
+func (o *ORM) UpdateByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return updateByPK(o.session(ctx), s, pks...)
+}
+
+// Same as Update, but only the given fields are written, e.g. UpdateColumns(article, "Title", "State")
+func (o *ORM) UpdateColumns(s interface{}, fields ...string) error {
+	return o.UpdateColumnsContext(context.Background(), s, fields...)
+}
+
+func (o *ORM) UpdateColumnsContext(ctx context.Context, s interface{}, fields ...string) error {
+	return updateColumns(o.session(ctx), s, fields...)
+}
+
+// Only write the fields changed since s was loaded from db, which requires an embedded Snapshot in s.
+// Nothing is written if there is no change, and all the fields are written if s is not tracked
+func (o *ORM) UpdateChanged(s interface{}) error {
+	return o.UpdateChangedContext(context.Background(), s)
+}
+
+func (o *ORM) UpdateChangedContext(ctx context.Context, s interface{}) error {
+	return updateChanged(o.session(ctx), s)
+}
+
+// Delete the row identified by the `pk:"true"` field of s
+func (o *ORM) Delete(s interface{}) error {
+	return o.DeleteContext(context.Background(), s)
+}
+
+func (o *ORM) DeleteContext(ctx context.Context, s interface{}) error {
+	return deleteRow(o.session(ctx), s, -1)
+}
+
+// Delete the row of s's table with the given pk values, s is only used to resolve the table, e.g. DeleteByPK(&User{}, 1)
+func (o *ORM) DeleteByPK(s interface{}, pks ...interface{}) error {
+	return o.DeleteByPKContext(context.Background(), s, pks...)
+}
+
+func (o *ORM) DeleteByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return deleteByPK(o.session(ctx), s, -1, pks...)
+}
+
+// Same as Delete, but returns a RowAffectError if no row is deleted
+func (o *ORM) DeleteWithRowAffectCheck(s interface{}) error {
+	return o.DeleteWithRowAffectCheckContext(context.Background(), s)
+}
+
+func (o *ORM) DeleteWithRowAffectCheckContext(ctx context.Context, s interface{}) error {
+	return deleteRow(o.session(ctx), s, 1)
+}
+
+// Same as DeleteByPK, but returns a RowAffectError if no row is deleted
+func (o *ORM) DeleteByPKWithRowAffectCheck(s interface{}, pks ...interface{}) error {
+	return o.DeleteByPKWithRowAffectCheckContext(context.Background(), s, pks...)
+}
+
+func (o *ORM) DeleteByPKWithRowAffectCheckContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return deleteByPK(o.session(ctx), s, 1, pks...)
 }
 
 func (o *ORM) ExecWithRowAffectCheck(n int64, query string, args ...interface{}) error {
-	return execWithRowAffectCheck(o.db, n, query, args...)
+	return o.ExecWithRowAffectCheckContext(context.Background(), n, query, args...)
+}
+
+func (o *ORM) ExecWithRowAffectCheckContext(ctx context.Context, n int64, query string, args ...interface{}) error {
+	return execWithRowAffectCheck(o.session(ctx), n, query, args...)
 }
 
 func (o *ORM) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return exec(o.db, query, args...)
+	return o.ExecContext(context.Background(), query, args...)
+}
+
+func (o *ORM) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return exec(o.session(ctx), query, args...)
 }
 
 func (o *ORM) ExecWithParam(paramQuery string, paramMap interface{}) (sql.Result, error) {
-	return execWithParam(o.db, paramQuery, paramMap)
+	return o.ExecWithParamContext(context.Background(), paramQuery, paramMap)
+}
+
+func (o *ORM) ExecWithParamContext(ctx context.Context, paramQuery string, paramMap interface{}) (sql.Result, error) {
+	return execWithParam(o.session(ctx), paramQuery, paramMap)
 }
 
 func (o *ORM) DoTransaction(f func(*ORMTran) error) error {
-	trans, err := o.Begin()
+	return o.DoTransactionContext(context.Background(), nil, f)
+}
+
+// Run f in a transaction, which is rolled back if f returns an error or panics, otherwise committed,
+// and the error of commit is returned
+func (o *ORM) DoTransactionContext(ctx context.Context, opts *sql.TxOptions, f func(*ORMTran) error) (err error) {
+	trans, err := o.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
@@ -192,50 +514,157 @@ func (o *ORM) DoTransaction(f func(*ORMTran) error) error {
 				panic(perr)
 			}
 			return
-		} else {
-			err = trans.Commit()
-			return
 		}
+		err = trans.Commit()
 	}()
-	err = f(trans)
-	return err
+	return f(trans)
 }
 
 func (o *ORM) DoTransactionMore(f func(*ORMTran) (interface{}, error)) (interface{}, error) {
-	trans, err := o.Begin()
+	return o.DoTransactionMoreContext(context.Background(), nil, f)
+}
+
+// Same as DoTransactionContext, but returns the result of f
+func (o *ORM) DoTransactionMoreContext(ctx context.Context, opts *sql.TxOptions,
+	f func(*ORMTran) (interface{}, error)) (ret interface{}, err error) {
+	trans, err := o.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		if err != nil {
+		perr := recover()
+		if err != nil || perr != nil {
 			trans.Rollback()
-			return
-		} else {
-			err = trans.Commit()
+			if perr != nil {
+				panic(perr)
+			}
 			return
 		}
+		err = trans.Commit()
 	}()
-	return f(trans)
+	ret, err = f(trans)
+	return ret, err
 }
 
 type ORMTran struct {
-	tx *sql.Tx
+	tx      *sql.Tx
+	dialect Dialect
+}
+
+func (o *ORMTran) session(ctx context.Context) Tdx {
+	return newSession(o.tx, ctx, o.dialect)
 }
 
 func (o *ORMTran) SelectOne(s interface{}, query string, args ...interface{}) error {
-	return selectOne(o.tx, s, query, args...)
+	return o.SelectOneContext(context.Background(), s, query, args...)
+}
+
+func (o *ORMTran) SelectOneContext(ctx context.Context, s interface{}, query string, args ...interface{}) error {
+	return selectOne(o.session(ctx), s, query, args...)
+}
+
+func (o *ORMTran) Query(s interface{}) *QueryBuilder {
+	return o.QueryContext(context.Background(), s)
+}
+
+func (o *ORMTran) QueryContext(ctx context.Context, s interface{}) *QueryBuilder {
+	return newQueryBuilder(o.session(ctx), s)
+}
+
+func (o *ORMTran) Preload(s interface{}, paths ...string) error {
+	return o.PreloadContext(context.Background(), s, paths...)
+}
+
+func (o *ORMTran) PreloadContext(ctx context.Context, s interface{}, paths ...string) error {
+	return preloadHolder(o.session(ctx), s, newPreloadTree(paths))
 }
 
 func (o *ORMTran) Insert(s interface{}) error {
-	return insert(o.tx, s)
+	return o.InsertContext(context.Background(), s)
+}
+
+func (o *ORMTran) InsertContext(ctx context.Context, s interface{}) error {
+	return insert(o.session(ctx), s)
 }
 
 func (o *ORMTran) InsertBatch(s []interface{}) error {
-	return insertBatch(o.tx, s)
+	return o.InsertBatchContext(context.Background(), s)
+}
+
+func (o *ORMTran) InsertBatchContext(ctx context.Context, s []interface{}) error {
+	return insertBatch(o.session(ctx), s)
+}
+
+func (o *ORMTran) Update(s interface{}) error {
+	return o.UpdateContext(context.Background(), s)
+}
+
+func (o *ORMTran) UpdateContext(ctx context.Context, s interface{}) error {
+	return update(o.session(ctx), s)
+}
+
+func (o *ORMTran) UpdateByPK(s interface{}, pks ...interface{}) error {
+	return o.UpdateByPKContext(context.Background(), s, pks...)
+}
+
+func (o *ORMTran) UpdateByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return updateByPK(o.session(ctx), s, pks...)
+}
+
+func (o *ORMTran) UpdateColumns(s interface{}, fields ...string) error {
+	return o.UpdateColumnsContext(context.Background(), s, fields...)
+}
+
+func (o *ORMTran) UpdateColumnsContext(ctx context.Context, s interface{}, fields ...string) error {
+	return updateColumns(o.session(ctx), s, fields...)
+}
+
+func (o *ORMTran) UpdateChanged(s interface{}) error {
+	return o.UpdateChangedContext(context.Background(), s)
+}
+
+func (o *ORMTran) UpdateChangedContext(ctx context.Context, s interface{}) error {
+	return updateChanged(o.session(ctx), s)
+}
+
+func (o *ORMTran) Delete(s interface{}) error {
+	return o.DeleteContext(context.Background(), s)
+}
+
+func (o *ORMTran) DeleteContext(ctx context.Context, s interface{}) error {
+	return deleteRow(o.session(ctx), s, -1)
+}
+
+func (o *ORMTran) DeleteByPK(s interface{}, pks ...interface{}) error {
+	return o.DeleteByPKContext(context.Background(), s, pks...)
+}
+
+func (o *ORMTran) DeleteByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return deleteByPK(o.session(ctx), s, -1, pks...)
+}
+
+func (o *ORMTran) DeleteWithRowAffectCheck(s interface{}) error {
+	return o.DeleteWithRowAffectCheckContext(context.Background(), s)
+}
+
+func (o *ORMTran) DeleteWithRowAffectCheckContext(ctx context.Context, s interface{}) error {
+	return deleteRow(o.session(ctx), s, 1)
+}
+
+func (o *ORMTran) DeleteByPKWithRowAffectCheck(s interface{}, pks ...interface{}) error {
+	return o.DeleteByPKWithRowAffectCheckContext(context.Background(), s, pks...)
+}
+
+func (o *ORMTran) DeleteByPKWithRowAffectCheckContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return deleteByPK(o.session(ctx), s, 1, pks...)
 }
 
 func (o *ORMTran) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return exec(o.tx, query, args...)
+	return o.ExecContext(context.Background(), query, args...)
+}
+
+func (o *ORMTran) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return exec(o.session(ctx), query, args...)
 }
 
 func (o *ORMTran) Commit() error {
@@ -246,37 +675,66 @@ func (o *ORMTran) Rollback() error {
 	return o.tx.Rollback()
 }
 
-func (o *ORMTran) SelectByPK(s interface{}, pk interface{}) error {
-	return selectByPK(o.tx, s, pk)
+func (o *ORMTran) SelectByPK(s interface{}, pks ...interface{}) error {
+	return o.SelectByPKContext(context.Background(), s, pks...)
+}
+
+func (o *ORMTran) SelectByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return selectByPK(o.session(ctx), s, pks...)
 }
 
 func (o *ORMTran) Select(s interface{}, query string, args ...interface{}) error {
-	return selectMany(o.tx, s, query, args...)
+	return o.SelectContext(context.Background(), s, query, args...)
+}
+
+func (o *ORMTran) SelectContext(ctx context.Context, s interface{}, query string, args ...interface{}) error {
+	return selectMany(o.session(ctx), s, query, args...)
 }
 
 func (o *ORMTran) SelectInt(query string, args ...interface{}) (int64, error) {
-	return selectInt(o.tx, query, args...)
+	return o.SelectIntContext(context.Background(), query, args...)
+}
+
+func (o *ORMTran) SelectIntContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return selectInt(o.session(ctx), query, args...)
 }
 
 func (o *ORMTran) SelectFloat64(query string, args ...interface{}) (float64, error) {
-	return selectFloat64(o.tx, query, args...)
+	return o.SelectFloat64Context(context.Background(), query, args...)
+}
+
+func (o *ORMTran) SelectFloat64Context(ctx context.Context, query string, args ...interface{}) (float64, error) {
+	return selectFloat64(o.session(ctx), query, args...)
 }
 
 func (o *ORMTran) SelectStr(query string, args ...interface{}) (string, error) {
-	return selectStr(o.tx, query, args...)
+	return o.SelectStrContext(context.Background(), query, args...)
+}
+
+func (o *ORMTran) SelectStrContext(ctx context.Context, query string, args ...interface{}) (string, error) {
+	return selectStr(o.session(ctx), query, args...)
 }
 
 func (o *ORMTran) ExecWithParam(paramQuery string, paramMap interface{}) (sql.Result, error) {
-	return execWithParam(o.tx, paramQuery, paramMap)
+	return o.ExecWithParamContext(context.Background(), paramQuery, paramMap)
+}
+
+func (o *ORMTran) ExecWithParamContext(ctx context.Context, paramQuery string, paramMap interface{}) (sql.Result, error) {
+	return execWithParam(o.session(ctx), paramQuery, paramMap)
 }
 
 func (o *ORMTran) ExecWithRowAffectCheck(n int64, query string, args ...interface{}) error {
-	return execWithRowAffectCheck(o.tx, n, query, args...)
+	return o.ExecWithRowAffectCheckContext(context.Background(), n, query, args...)
+}
+
+func (o *ORMTran) ExecWithRowAffectCheckContext(ctx context.Context, n int64, query string, args ...interface{}) error {
+	return execWithRowAffectCheck(o.session(ctx), n, query, args...)
 }
 
 // Section of package method, which is a convenient way to the same method on Default orm instance
 func IsRowAffectError(err error) bool {
-	return strings.HasPrefix(err.Error(), "[RowAffectCheckError]")
+	var raErr *RowAffectError
+	return errors.As(err, &raErr)
 }
 
 func Close() error {
@@ -286,12 +744,12 @@ func Close() error {
 // Register the table into ORM object, so that you can check whether the struct fields
 // match the columns in db. If you don't register table, then you'll lose the functionality
 // of CheckTables/GetTableByName/TruncateTables, which is OK since it's not key functions
-func AddTable(s interface{}) {
-	Default.AddTable(s)
+func AddTable(s interface{}) error {
+	return Default.AddTable(s)
 }
 
-func CheckTables() {
-	Default.CheckTables()
+func CheckTables() error {
+	return Default.CheckTables()
 }
 
 func GetTableByName(name string) interface{} {
@@ -310,58 +768,191 @@ func SelectOne(s interface{}, query string, args ...interface{}) error {
 	return Default.SelectOne(s, query, args...)
 }
 
-func SelectByPK(s interface{}, pk interface{}) error {
-	return Default.SelectByPK(s, pk)
+func SelectOneContext(ctx context.Context, s interface{}, query string, args ...interface{}) error {
+	return Default.SelectOneContext(ctx, s, query, args...)
+}
+
+func SelectByPK(s interface{}, pks ...interface{}) error {
+	return Default.SelectByPK(s, pks...)
+}
+
+func SelectByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return Default.SelectByPKContext(ctx, s, pks...)
 }
 
 func Select(s interface{}, query string, args ...interface{}) error {
 	return Default.Select(s, query, args...)
 }
 
+func SelectContext(ctx context.Context, s interface{}, query string, args ...interface{}) error {
+	return Default.SelectContext(ctx, s, query, args...)
+}
+
 func SelectRawSet(query string, args ...interface{}) ([]map[string]string, error) {
 	return Default.SelectRawSet(query, args...)
 }
 
+func SelectRawSetContext(ctx context.Context, query string, args ...interface{}) ([]map[string]string, error) {
+	return Default.SelectRawSetContext(ctx, query, args...)
+}
+
 func SelectRaw(query string, args ...interface{}) ([]string, [][]string, error) {
 	return Default.SelectRaw(query, args...)
 }
 
+func SelectRawContext(ctx context.Context, query string, args ...interface{}) ([]string, [][]string, error) {
+	return Default.SelectRawContext(ctx, query, args...)
+}
+
 func SelectStr(query string, args ...interface{}) (string, error) {
 	return Default.SelectStr(query, args...)
 }
 
+func SelectStrContext(ctx context.Context, query string, args ...interface{}) (string, error) {
+	return Default.SelectStrContext(ctx, query, args...)
+}
+
 func SelectInt(query string, args ...interface{}) (int64, error) {
 	return Default.SelectInt(query, args...)
 }
 
+func SelectIntContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return Default.SelectIntContext(ctx, query, args...)
+}
+
 func SelectFloat64(query string, args ...interface{}) (float64, error) {
 	return Default.SelectFloat64(query, args...)
 }
 
+func SelectFloat64Context(ctx context.Context, query string, args ...interface{}) (float64, error) {
+	return Default.SelectFloat64Context(ctx, query, args...)
+}
+
+func Query(s interface{}) *QueryBuilder {
+	return Default.Query(s)
+}
+
+func QueryContext(ctx context.Context, s interface{}) *QueryBuilder {
+	return Default.QueryContext(ctx, s)
+}
+
 func Insert(s interface{}) error {
 	return Default.Insert(s)
 }
 
+func InsertContext(ctx context.Context, s interface{}) error {
+	return Default.InsertContext(ctx, s)
+}
+
 func InsertBatch(s []interface{}) error {
 	return Default.InsertBatch(s)
 }
 
+func InsertBatchContext(ctx context.Context, s []interface{}) error {
+	return Default.InsertBatchContext(ctx, s)
+}
+
+func Update(s interface{}) error {
+	return Default.Update(s)
+}
+
+func UpdateContext(ctx context.Context, s interface{}) error {
+	return Default.UpdateContext(ctx, s)
+}
+
+func UpdateByPK(s interface{}, pks ...interface{}) error {
+	return Default.UpdateByPK(s, pks...)
+}
+
+func UpdateByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return Default.UpdateByPKContext(ctx, s, pks...)
+}
+
+func UpdateColumns(s interface{}, fields ...string) error {
+	return Default.UpdateColumns(s, fields...)
+}
+
+func UpdateColumnsContext(ctx context.Context, s interface{}, fields ...string) error {
+	return Default.UpdateColumnsContext(ctx, s, fields...)
+}
+
+func UpdateChanged(s interface{}) error {
+	return Default.UpdateChanged(s)
+}
+
+func UpdateChangedContext(ctx context.Context, s interface{}) error {
+	return Default.UpdateChangedContext(ctx, s)
+}
+
+func Delete(s interface{}) error {
+	return Default.Delete(s)
+}
+
+func DeleteContext(ctx context.Context, s interface{}) error {
+	return Default.DeleteContext(ctx, s)
+}
+
+func DeleteByPK(s interface{}, pks ...interface{}) error {
+	return Default.DeleteByPK(s, pks...)
+}
+
+func DeleteByPKContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return Default.DeleteByPKContext(ctx, s, pks...)
+}
+
+func DeleteWithRowAffectCheck(s interface{}) error {
+	return Default.DeleteWithRowAffectCheck(s)
+}
+
+func DeleteWithRowAffectCheckContext(ctx context.Context, s interface{}) error {
+	return Default.DeleteWithRowAffectCheckContext(ctx, s)
+}
+
+func DeleteByPKWithRowAffectCheck(s interface{}, pks ...interface{}) error {
+	return Default.DeleteByPKWithRowAffectCheck(s, pks...)
+}
+
+func DeleteByPKWithRowAffectCheckContext(ctx context.Context, s interface{}, pks ...interface{}) error {
+	return Default.DeleteByPKWithRowAffectCheckContext(ctx, s, pks...)
+}
+
 func ExecWithRowAffectCheck(n int64, query string, args ...interface{}) error {
 	return Default.ExecWithRowAffectCheck(n, query, args...)
 }
 
+func ExecWithRowAffectCheckContext(ctx context.Context, n int64, query string, args ...interface{}) error {
+	return Default.ExecWithRowAffectCheckContext(ctx, n, query, args...)
+}
+
 func Exec(query string, args ...interface{}) (sql.Result, error) {
 	return Default.Exec(query, args...)
 }
 
+func ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return Default.ExecContext(ctx, query, args...)
+}
+
 func ExecWithParam(paramQuery string, paramMap interface{}) (sql.Result, error) {
 	return Default.ExecWithParam(paramQuery, paramMap)
 }
 
+func ExecWithParamContext(ctx context.Context, paramQuery string, paramMap interface{}) (sql.Result, error) {
+	return Default.ExecWithParamContext(ctx, paramQuery, paramMap)
+}
+
 func DoTransaction(f func(*ORMTran) error) error {
 	return Default.DoTransaction(f)
 }
 
 func DoTransactionMore(f func(*ORMTran) (interface{}, error)) (interface{}, error) {
 	return Default.DoTransactionMore(f)
+}
+
+func DoTransactionContext(ctx context.Context, opts *sql.TxOptions, f func(*ORMTran) error) error {
+	return Default.DoTransactionContext(ctx, opts, f)
+}
+
+func DoTransactionMoreContext(ctx context.Context, opts *sql.TxOptions,
+	f func(*ORMTran) (interface{}, error)) (interface{}, error) {
+	return Default.DoTransactionMoreContext(ctx, opts, f)
 }
\ No newline at end of file
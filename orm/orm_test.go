@@ -1,10 +1,16 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -235,7 +241,7 @@ func TestExecParam(t *testing.T) {
 }
 
 func TestAutoIncreaseKey(t *testing.T) {
-	oneTestScope(func(orm *ORM) {
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
 		testObj := &TestOrmA123{
 			OtherId:     1,
 			Description: "test orm 1测试",
@@ -673,7 +679,7 @@ func TestPanicHandlingInTransaction(t *testing.T) {
 }
 
 func TestSelectFloat64(t *testing.T) {
-	oneTestScope(func(orm *ORM) {
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
 		list := make([]interface{}, 0, 2)
 		for i := 0; i < 2; i++ {
 			list = append(list, &TestOrmA123{
@@ -692,3 +698,1482 @@ func TestSelectFloat64(t *testing.T) {
 		}
 	})
 }
+
+func TestUpdate(t *testing.T) {
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
+		testObj := &TestOrmA123{
+			OtherId:     1,
+			Description: "test orm 1",
+			StartDate:   time.Now(),
+			EndDate:     time.Now(),
+		}
+		orm.Insert(testObj)
+
+		testObj.OtherId = 2
+		testObj.Description = "updated"
+		testObj.Name = sql.NullString{String: "name", Valid: true}
+		if err := orm.Update(testObj); err != nil {
+			t.Fatal(err)
+		}
+		var loadedObj TestOrmA123
+		if err := orm.SelectByPK(&loadedObj, testObj.TestId); err != nil {
+			t.Fatal(err)
+		}
+		if loadedObj.OtherId != 2 || loadedObj.Description != "updated" || loadedObj.Name.String != "name" {
+			t.Fatal("fields not updated", loadedObj)
+		}
+
+		// no-op update should not be treated as missing row
+		if err := orm.Update(testObj); err != nil {
+			t.Fatal(err)
+		}
+
+		err := orm.DoTransaction(func(ot *ORMTran) error {
+			testObj.Description = "updated in tran"
+			return ot.UpdateByPK(testObj, testObj.TestId)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orm.SelectByPK(&loadedObj, testObj.TestId)
+		if loadedObj.Description != "updated in tran" {
+			t.Fatal("fields not updated", loadedObj)
+		}
+
+		err = orm.UpdateByPK(testObj, testObj.TestId+100)
+		if err == nil || !IsRowAffectError(err) {
+			t.Fatal("should return row affect error", err)
+		}
+	})
+}
+
+func TestDelete(t *testing.T) {
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
+		testObj := &TestOrmA123{
+			OtherId:     1,
+			Description: "test orm 1",
+			StartDate:   time.Now(),
+			EndDate:     time.Now(),
+		}
+		orm.Insert(testObj)
+		testObj2 := &TestOrmA123{
+			OtherId:     2,
+			Description: "test orm 2",
+			StartDate:   time.Now(),
+			EndDate:     time.Now(),
+		}
+		orm.Insert(testObj2)
+
+		if err := orm.Delete(testObj); err != nil {
+			t.Fatal(err)
+		}
+		var loadedObj TestOrmA123
+		if err := orm.SelectByPK(&loadedObj, testObj.TestId); err != sql.ErrNoRows {
+			t.Fatal("should be deleted", err)
+		}
+
+		err := orm.DeleteWithRowAffectCheck(testObj)
+		if err == nil || !IsRowAffectError(err) {
+			t.Fatal("should return row affect error", err)
+		}
+
+		err = orm.DoTransaction(func(ot *ORMTran) error {
+			return ot.DeleteByPKWithRowAffectCheck(&TestOrmA123{}, testObj2.TestId)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := orm.SelectInt("select count(*) from test_orm_a123"); n != 0 {
+			t.Fatal("should have no rows left", n)
+		}
+	})
+}
+
+type TestOrmE333 struct {
+	Snapshot
+	TestOrmEId int64 `pk:"true" ai:"true"`
+	Title      string
+	State      int
+}
+
+func TestUpdateColumnsAndChanged(t *testing.T) {
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
+		// the table of SQLite is created by sqliteTestScope
+		if orm.Dialect() == MySQL {
+			_, err := orm.Exec(`
+        CREATE TABLE IF NOT EXISTS test_orm_e333 (
+          test_orm_e_id BIGINT(20) NOT NULL AUTO_INCREMENT,
+          title VARCHAR(1024) NOT NULL,
+          state INT NOT NULL,
+          PRIMARY KEY (test_orm_e_id))
+        ENGINE = InnoDB;`)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_e333;")
+
+		testObj := &TestOrmE333{Title: "title", State: 1}
+		if err := orm.Insert(testObj); err != nil {
+			t.Fatal(err)
+		}
+
+		var loaded, concurrent TestOrmE333
+		orm.SelectByPK(&loaded, testObj.TestOrmEId)
+		orm.SelectByPK(&concurrent, testObj.TestOrmEId)
+
+		concurrent.State = 2
+		if err := orm.UpdateColumns(&concurrent, "State"); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.UpdateColumns(&concurrent, "NoSuchField"); err == nil {
+			t.Fatal("should fail on missing field")
+		}
+
+		// only title is changed, so the concurrent write to state should be kept
+		loaded.Title = "new title"
+		if err := orm.UpdateChanged(&loaded); err != nil {
+			t.Fatal(err)
+		}
+		var result TestOrmE333
+		orm.SelectByPK(&result, testObj.TestOrmEId)
+		if result.Title != "new title" || result.State != 2 {
+			t.Fatal("incorrect partial update", result)
+		}
+
+		// nothing changed after update
+		if err := orm.UpdateChanged(&loaded); err != nil {
+			t.Fatal(err)
+		}
+
+		// the fields not written by UpdateColumns are still changed
+		loaded.Title, loaded.State = "partial", 5
+		if err := orm.UpdateColumns(&loaded, "Title"); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.UpdateChanged(&loaded); err != nil {
+			t.Fatal(err)
+		}
+		orm.SelectByPK(&result, testObj.TestOrmEId)
+		if result.Title != "partial" || result.State != 5 {
+			t.Fatal("the changed field is not written", result)
+		}
+
+		// the snapshot is kept after updating another row
+		other := &TestOrmE333{Title: "other", State: 1}
+		orm.Insert(other)
+		loaded.State = 6
+		if err := orm.UpdateByPK(&loaded, other.TestOrmEId); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.UpdateChanged(&loaded); err != nil {
+			t.Fatal(err)
+		}
+		orm.SelectByPK(&result, testObj.TestOrmEId)
+		if result.State != 6 {
+			t.Fatal("the changed field is not written", result)
+		}
+	})
+}
+
+type TestOrmF444 struct {
+	TestId     int64 `pk:"true"`
+	TestOrmCId int64 `pk:"true"`
+	Weight     int
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
+		// the table of SQLite is created by sqliteTestScope
+		if orm.Dialect() == MySQL {
+			_, err := orm.Exec(`
+        CREATE TABLE IF NOT EXISTS test_orm_f444 (
+          test_id BIGINT(20) NOT NULL,
+          test_orm_c_id BIGINT(20) NOT NULL,
+          weight INT NOT NULL,
+          PRIMARY KEY (test_id, test_orm_c_id))
+        ENGINE = InnoDB;`)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_f444;")
+
+		for i := int64(1); i <= 3; i++ {
+			if err := orm.Insert(&TestOrmF444{TestId: 1, TestOrmCId: i, Weight: int(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var loaded TestOrmF444
+		if err := orm.SelectByPK(&loaded, 1, 2); err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Weight != 2 {
+			t.Fatal("incorrect result", loaded)
+		}
+		if err := orm.SelectByPK(&loaded, 1); err == nil {
+			t.Fatal("should fail with missing pk value")
+		}
+
+		loaded.Weight = 20
+		if err := orm.Update(&loaded); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.DeleteByPKWithRowAffectCheck(&TestOrmF444{}, 1, 3); err != nil {
+			t.Fatal(err)
+		}
+
+		var all []*TestOrmF444
+		orm.Select(&all, "select * from test_orm_f444 order by test_orm_c_id")
+		if len(all) != 2 || all[0].Weight != 1 || all[1].Weight != 20 {
+			t.Fatal("incorrect result", all)
+		}
+	})
+}
+
+func TestQueryBuilder(t *testing.T) {
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
+		for i := 0; i < 10; i++ {
+			orm.Insert(&TestOrmA123{
+				OtherId:     int64(i % 2),
+				Description: fmt.Sprintf("test orm %d", i),
+				StartDate:   time.Now(),
+				EndDate:     time.Now(),
+			})
+		}
+
+		var list []*TestOrmA123
+		q := orm.Query(&list).Where("other_id = ?", 1).OrderBy("test_id DESC").Limit(2).Offset(1)
+		query, args := q.SQL()
+		if query != "SELECT * FROM `test_orm_a123` WHERE other_id = ? ORDER BY `test_id` DESC LIMIT 2 OFFSET 1" ||
+			len(args) != 1 {
+			t.Fatal("incorrect sql", query, args)
+		}
+		if err := q.All(); err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].TestId != 8 || list[1].TestId != 6 {
+			t.Fatal("incorrect result", list)
+		}
+
+		n, err := orm.Query(&TestOrmA123{}).Where("other_id = ?", 0).Or("test_id = ?", 2).Count()
+		if err != nil || n != 6 {
+			t.Fatal("incorrect count", n, err)
+		}
+
+		// the args of the caller are not modified by the combined conditions
+		args = make([]interface{}, 1, 2)
+		args[0] = 1
+		q1 := orm.Query(&TestOrmA123{}).Where("other_id = ?", args...).And("test_id > ?", 5)
+		q2 := orm.Query(&TestOrmA123{}).Where("other_id = ?", args...).And("test_id > ?", 7)
+		if _, args1 := q1.SQL(); args1[1] != 5 || args[:2][1] != nil {
+			t.Fatal("the args should not be shared", args1, args[:2])
+		}
+		if n, err := q2.Count(); err != nil || n != 2 {
+			t.Fatal("incorrect count", n, err)
+		}
+
+		err = orm.DoTransaction(func(ot *ORMTran) error {
+			var one TestOrmA123
+			err := ot.Query(&one).Where("other_id = ?", 1).And("test_id > ?", 5).OrderBy("test_id").One()
+			if err != nil {
+				return err
+			}
+			if one.TestId != 6 {
+				t.Fatal("incorrect result", one)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFilter(t *testing.T) {
+	for _, f := range []Filter{
+		NewFilter("other_id", "is", 1),
+		NewFilter("other_id", "in", 1),
+		NewFilter("other_id", "=", 1).Or(NewFilter("other_id", "not in", "1")),
+	} {
+		if f.Err() == nil {
+			t.Fatal("should be invalid filter", f)
+		}
+		var list []*TestOrmA123
+		if err := NewORM().Query(&list).Filter(f).All(); err != f.Err() {
+			t.Fatal("should return the error of filter", err)
+		}
+		if _, err := NewORM().Query(&TestOrmA123{}).Filter(f).Delete(); err != f.Err() {
+			t.Fatal("should return the error of filter", err)
+		}
+	}
+
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
+		for i := 0; i < 5; i++ {
+			orm.Insert(&TestOrmA123{
+				OtherId:     int64(i),
+				Description: fmt.Sprintf("test orm %d", i),
+				StartDate:   time.Now(),
+				EndDate:     time.Now(),
+			})
+		}
+
+		filter := NewFilter("other_id", "in", []int64{1, 2, 3}).And(NewFilter("description", "like", "test%"))
+		cond, args := filter.Where()
+		if cond != "(`other_id` IN (?,?,?)) AND (`description` LIKE ?)" || len(args) != 4 {
+			t.Fatal("incorrect filter", cond, args)
+		}
+		var list []*TestOrmA123
+		if err := orm.Query(&list).Filter(filter).All(); err != nil || len(list) != 3 {
+			t.Fatal("incorrect result", list, err)
+		}
+
+		n, err := orm.Query(&TestOrmA123{}).Filter(NewFilter("other_id", "in", []int64{}).Or(NewFilter("other_id", ">=", 3))).Delete()
+		if err != nil || n != 2 {
+			t.Fatal("incorrect deleted rows", n, err)
+		}
+	})
+}
+
+func TestContext(t *testing.T) {
+	oneTestScope(func(orm *ORM) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		testObj := &TestOrmA123{
+			OtherId:     1,
+			Description: "test orm 1",
+			StartDate:   time.Now(),
+			EndDate:     time.Now(),
+		}
+		if err := orm.InsertContext(ctx, testObj); err != nil {
+			t.Fatal(err)
+		}
+		var loadedObj TestOrmA123
+		if err := orm.SelectByPKContext(ctx, &loadedObj, testObj.TestId); err != nil {
+			t.Fatal(err)
+		}
+
+		canceled, cancelNow := context.WithCancel(context.Background())
+		cancelNow()
+		var list []*TestOrmA123
+		if err := orm.SelectContext(canceled, &list, "select * from test_orm_a123"); err == nil {
+			t.Fatal("should fail with canceled context")
+		}
+		err := orm.DoTransactionContext(canceled, &sql.TxOptions{ReadOnly: true}, func(ot *ORMTran) error {
+			return nil
+		})
+		if err == nil {
+			t.Fatal("should fail with canceled context")
+		}
+		err = orm.DoTransactionContext(ctx, nil, func(ot *ORMTran) error {
+			return ot.UpdateColumnsContext(ctx, testObj, "Description")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT * FROM `test_orm_a123` WHERE `other_id` = ? AND description = 'what?' AND name = \"a`b\" LIMIT ?"
+	if rebind(MySQL, query) != query {
+		t.Fatal("mysql query should not be changed")
+	}
+	expected := `SELECT * FROM "test_orm_a123" WHERE "other_id" = $1 AND description = 'what?' AND name = "a` + "`" + `b" LIMIT $2`
+	if ret := rebind(PostgreSQL, query); ret != expected {
+		t.Fatal("incorrect postgres query", ret)
+	}
+	expected = `SELECT * FROM "test_orm_a123" WHERE "other_id" = ? AND description = 'what?' AND name = "a` + "`" + `b" LIMIT ?`
+	if ret := rebind(SQLite, query); ret != expected {
+		t.Fatal("incorrect sqlite query", ret)
+	}
+	if ret := rebind(PostgreSQL, "update t set a = 'it''s ?' where b = ?"); ret != "update t set a = 'it''s ?' where b = $1" {
+		t.Fatal("incorrect escaped quote", ret)
+	}
+}
+
+// fakeDriver returns the fixed rows like SQLite, i.e. TEXT as string, for the tests without database server,
+// and counts the committed and rolled back transactions
+type fakeDriver struct{}
+
+type fakeConn struct{}
+
+type fakeStmt struct{}
+
+type fakeTx struct{}
+
+type fakeRows struct {
+	next int
+}
+
+var fakeDialect = struct{ Dialect }{SQLite}
+
+var (
+	fakeCommits, fakeRollbacks int
+	fakeCommitErr              error
+)
+
+func init() {
+	sql.Register("glorm_fake", fakeDriver{})
+}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (tx fakeTx) Commit() error {
+	fakeCommits++
+	return fakeCommitErr
+}
+
+func (tx fakeTx) Rollback() error {
+	fakeRollbacks++
+	return nil
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"user_id", "name", "active", "note"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next > 0 {
+		return io.EOF
+	}
+	r.next++
+	dest[0], dest[1], dest[2], dest[3] = int64(1), "name", true, nil
+	return nil
+}
+
+func TestSelectRawText(t *testing.T) {
+	orm := NewORMWithDialect(fakeDialect)
+	orm.db, _ = sql.Open("glorm_fake", "")
+	defer orm.Close()
+
+	set, err := orm.SelectRawSet("select user_id, name, active, note from user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(set, []map[string]string{{"UserId": "1", "Name": "name", "Active": "true"}}) {
+		t.Fatal("unexpected raw set", set)
+	}
+	cols, rows, err := orm.SelectRaw("select user_id, name, active, note from user")
+	if err != nil || len(cols) != 4 || !reflect.DeepEqual(rows, [][]string{{"1", "name", "true", ""}}) {
+		t.Fatal("unexpected raw rows", rows, err)
+	}
+}
+
+func TestDoTransactionRollback(t *testing.T) {
+	orm := NewORMWithDialect(fakeDialect)
+	orm.db, _ = sql.Open("glorm_fake", "")
+	defer orm.Close()
+
+	expected := errors.New("failed")
+	check := func(err error, commits, rollbacks int) {
+		t.Helper()
+		if err != expected || fakeCommits != commits || fakeRollbacks != rollbacks {
+			t.Fatal("unexpected transaction", err, fakeCommits, fakeRollbacks)
+		}
+		fakeCommits, fakeRollbacks = 0, 0
+	}
+	check(orm.DoTransaction(func(ot *ORMTran) error {
+		return expected
+	}), 0, 1)
+	_, err := orm.DoTransactionMore(func(ot *ORMTran) (interface{}, error) {
+		return nil, expected
+	})
+	check(err, 0, 1)
+
+	fakeCommitErr = expected
+	defer func() { fakeCommitErr = nil }()
+	check(orm.DoTransaction(func(ot *ORMTran) error {
+		return nil
+	}), 1, 0)
+	ret, err := orm.DoTransactionMore(func(ot *ORMTran) (interface{}, error) {
+		return 1, nil
+	})
+	check(err, 1, 0)
+	if ret != 1 {
+		t.Fatal("unexpected result", ret)
+	}
+}
+
+func TestOpenAndCheckTables(t *testing.T) {
+	oneTestScope(func(orm *ORM) {
+		if _, err := Open("root:@tcp(127.0.0.1:1)/test"); err == nil {
+			t.Fatal("should fail to connect")
+		}
+		o, err := NewORMWithOptions("root:@/test?parseTime=true&loc=Local", Options{MaxOpenConns: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.Close()
+		o.AddTable(TestOrmA123{})
+		o.AddTable(TestOrmC111{})
+		if err := o.CheckTables(); err != nil {
+			t.Fatal(err)
+		}
+
+		o.Exec("alter table test_orm_a123 add weight int not null default 0")
+		o.Exec("alter table test_orm_c111 add height int not null default 0")
+		err = o.CheckTables()
+		t.Logf("expected error here: %v", err)
+		if err == nil || !strings.Contains(err.Error(), "weight") || !strings.Contains(err.Error(), "height") {
+			t.Fatal("should report all the mismatched columns", err)
+		}
+	})
+}
+
+func TestTypedErrors(t *testing.T) {
+	type noPK struct {
+		Name string
+	}
+	err := selectByPK(nil, &noPK{}, 1)
+	if !errors.Is(err, ErrNoPrimaryKey) || err.Error() != "no_p_k does not have primary key" {
+		t.Fatal("should be ErrNoPrimaryKey", err)
+	}
+
+	_, err = getFieldValue(map[string]interface{}{}, "otherId")
+	var mfErr *MissingFieldError
+	if !errors.Is(err, ErrMissingField) || !errors.As(err, &mfErr) || mfErr.Column != "otherId" {
+		t.Fatal("should be MissingFieldError", err)
+	}
+
+	var list []TestOrmA123
+	err = selectMany(nil, &list, "select * from test_orm_a123")
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatal("should be ErrUnsupportedType", err)
+	}
+
+	err = fmt.Errorf("wrapped: %w", &RowAffectError{Query: "delete", Expected: 1, Actual: 0})
+	var raErr *RowAffectError
+	if !IsRowAffectError(err) || !errors.As(err, &raErr) || raErr.Expected != 1 || raErr.Actual != 0 {
+		t.Fatal("should be RowAffectError", err)
+	}
+	if IsRowAffectError(nil) {
+		t.Fatal("nil should not be RowAffectError")
+	}
+}
+
+func TestModelInfo(t *testing.T) {
+	mi := getModelInfo(reflect.TypeOf(TestOrmA123{}))
+	if mi != modelInfoOf(&[]*TestOrmA123{}) {
+		t.Fatal("model info should be cached")
+	}
+	if mi.table != "test_orm_a123" || len(mi.fields) != 9 || len(mi.relations) != 3 {
+		t.Fatal("wrong model info", mi.table, len(mi.fields), len(mi.relations))
+	}
+	if len(mi.pks) != 1 || mi.ai == nil || mi.ai.column != "test_id" {
+		t.Fatal("wrong primary key", mi.pks, mi.ai)
+	}
+	fields := mi.fieldsOf([]string{"test_id", "no_such_column", "created_at"})
+	if fields[0] != mi.ai || fields[1] != nil || fields[2] == nil || !fields[2].ignore {
+		t.Fatal("wrong fields of columns", fields)
+	}
+}
+
+// The scan targets of a large result set, the field lookup is done once for all the rows
+func BenchmarkScanTargets(b *testing.B) {
+	cols := []string{"test_id", "other_id", "description", "name", "start_date", "end_date",
+		"test_orm_d_id", "created_at", "updated_at"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fields := getModelInfo(reflect.TypeOf(TestOrmA123{})).fieldsOf(cols)
+		for k := 0; k < 10000; k++ {
+			scanTargets(reflect.New(reflect.TypeOf(TestOrmA123{})).Elem(), fields)
+		}
+	}
+}
+
+func BenchmarkSelectLargeResultSet(b *testing.B) {
+	oneTestScope(func(orm *ORM) {
+		for i := 0; i < 10; i++ {
+			batch := make([]interface{}, 1000)
+			for k := range batch {
+				batch[k] = &TestOrmC111{TestId: int64(k), Name: "benchmark"}
+			}
+			if err := orm.InsertBatch(batch); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ResetTimer()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var list []*TestOrmC111
+			if err := orm.Select(&list, "select * from test_orm_c111"); err != nil {
+				b.Fatal(err)
+			}
+			if len(list) != 10000 {
+				b.Fatal("should select 10000 rows", len(list))
+			}
+		}
+	})
+}
+
+type TestOrmG555 struct {
+	URLID   int64  `db:"url_id" pk:"true"`
+	IPV4    string `db:"ip_v4"`
+	Title   string
+	Comment string `db:"-"`
+}
+
+func TestDbTag(t *testing.T) {
+	mi := getModelInfo(reflect.TypeOf(TestOrmG555{}))
+	if len(mi.fields) != 3 || mi.pkColumns()[0] != "url_id" {
+		t.Fatal("wrong fields of db tag", mi.fields)
+	}
+	if mi.fieldOf("ip_v4").name != "IPV4" || mi.fieldOf("title").name != "Title" ||
+		mi.fieldOf("i_p_v4") != nil || mi.fieldOf("comment") != nil {
+		t.Fatal("wrong field of column")
+	}
+	cols, _, _, _, _ := columnsByStruct(&TestOrmG555{})
+	if cols != "`url_id`,`ip_v4`,`title`" {
+		t.Fatal("wrong insert columns", cols)
+	}
+	sets, _, _, _ := columnsForUpdate(&TestOrmG555{}, nil)
+	if sets != "`ip_v4` = ?,`title` = ?" {
+		t.Fatal("wrong update columns", sets)
+	}
+	errs := checkStruct(&TestOrmG555{}, []string{"url_id", "ip_v4", "title", "comment"}, "test_orm_g555")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "comment") {
+		t.Fatal("should only miss column comment", errs)
+	}
+}
+
+type TestOrmH666 struct {
+	Id   int64 `pk:"true" ai:"true"`
+	Name string
+}
+
+func (h TestOrmH666) TableName() string {
+	return "t_orm_h666"
+}
+
+type TestOrmI777 struct {
+	Id int64 `pk:"true"`
+}
+
+func (i *TestOrmI777) TableName() string {
+	return "t_orm_i777"
+}
+
+func TestTableName(t *testing.T) {
+	if getTableName(TestOrmH666{}) != "t_orm_h666" || getTableName(&[]*TestOrmI777{}) != "t_orm_i777" ||
+		getTableName(&TestOrmA123{}) != "test_orm_a123" {
+		t.Fatal("wrong table name")
+	}
+	oneTestScope(func(orm *ORM) {
+		orm.Exec("CREATE TABLE IF NOT EXISTS t_orm_h666 (id BIGINT(20) NOT NULL AUTO_INCREMENT, name VARCHAR(50) NOT NULL, PRIMARY KEY (id))")
+		defer orm.Exec("DROP TABLE IF EXISTS t_orm_h666")
+
+		if err := orm.Insert(&TestOrmH666{Name: "one"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.InsertBatch([]interface{}{&TestOrmH666{Name: "two"}, &TestOrmH666{Name: "three"}}); err != nil {
+			t.Fatal(err)
+		}
+		var loaded TestOrmH666
+		if err := orm.SelectByPK(&loaded, 3); err != nil || loaded.Name != "three" {
+			t.Fatal("failed to select by pk", err, loaded)
+		}
+		n, err := orm.Query(&TestOrmH666{}).Count()
+		if err != nil || n != 3 {
+			t.Fatal("failed to count", err, n)
+		}
+
+		orm.AddTable(TestOrmH666{})
+		if orm.GetTableByName("t_orm_h666") == nil {
+			t.Fatal("should register the table by its table name")
+		}
+		if err := orm.CheckTables(); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.TruncateTables(); err != nil {
+			t.Fatal(err)
+		}
+		n, _ = orm.SelectInt("select count(*) from t_orm_h666")
+		if n != 0 {
+			t.Fatal("should be truncated", n)
+		}
+	})
+}
+
+type testAddress struct {
+	City   string
+	Street string
+}
+
+type testAudit struct {
+	Name     string
+	Operator string
+}
+
+type TestOrmJ888 struct {
+	Timestamps
+	testAudit
+	Id   int64 `pk:"true" ai:"true"`
+	Name string
+	Home testAddress `prefix:"home_"`
+}
+
+type TestOrmW222 struct {
+	*Timestamps
+	Id   int64 `pk:"true" ai:"true"`
+	Name string
+}
+
+func TestEmbeddedStruct(t *testing.T) {
+	mi := getModelInfo(reflect.TypeOf(TestOrmJ888{}))
+	columns := make([]string, len(mi.fields))
+	for i, f := range mi.fields {
+		columns[i] = f.column
+	}
+	if strings.Join(columns, ",") != "created_at,updated_at,operator,id,name,home_city,home_street" {
+		t.Fatal("wrong columns", columns)
+	}
+	if mi.byName["Name"].index[0] != 3 || mi.byName["Home.City"].column != "home_city" {
+		t.Fatal("wrong fields", mi.byName)
+	}
+	cols, _, _, _, _ := columnsByStruct(&TestOrmJ888{})
+	if cols != "`operator`,`name`,`home_city`,`home_street`" {
+		t.Fatal("wrong insert columns", cols)
+	}
+	// the embedded pointer is not a column
+	mi = getModelInfo(reflect.TypeOf(TestOrmW222{}))
+	if len(mi.fields) != 2 || mi.fields[0].column != "id" || mi.fields[1].column != "name" || mi.fieldOf("timestamps") != nil {
+		t.Fatal("wrong columns of embedded pointer", mi.fields)
+	}
+	cols, _, _, _, _ = columnsByStruct(&TestOrmW222{Name: "a"})
+	if cols != "`name`" {
+		t.Fatal("wrong insert columns of embedded pointer", cols)
+	}
+
+	oneTestScope(func(orm *ORM) {
+		orm.Exec(`CREATE TABLE IF NOT EXISTS test_orm_j888 (
+			id BIGINT(20) NOT NULL AUTO_INCREMENT,
+			name VARCHAR(50) NOT NULL,
+			operator VARCHAR(50) NOT NULL,
+			home_city VARCHAR(50) NOT NULL,
+			home_street VARCHAR(50) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (id))`)
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_j888")
+
+		obj := TestOrmJ888{Name: "a", Home: testAddress{City: "Beijing", Street: "Chang'an"}}
+		obj.Operator = "admin"
+		if err := orm.Insert(&obj); err != nil {
+			t.Fatal(err)
+		}
+		obj.Home.City = "Shanghai"
+		if err := orm.Update(&obj); err != nil {
+			t.Fatal(err)
+		}
+		var list []*TestOrmJ888
+		if err := orm.Select(&list, "select * from test_orm_j888"); err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].Operator != "admin" || list[0].Home.City != "Shanghai" ||
+			list[0].CreatedAt.IsZero() {
+			t.Fatal("failed to select embedded fields", list)
+		}
+		orm.AddTable(TestOrmJ888{})
+		if err := orm.CheckTables(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+type TestOrmK999 struct {
+	Snapshot
+	Id          int64 `pk:"true" ai:"true"`
+	Title       *string
+	Views       *int64
+	PublishedAt *time.Time
+}
+
+func TestNullableFields(t *testing.T) {
+	views := int64(5)
+	if columnValue(reflect.ValueOf(&views)) != views || columnValue(reflect.ValueOf((*int64)(nil))) != nil {
+		t.Fatal("should dereference the pointer field")
+	}
+
+	oneTestScope(func(orm *ORM) {
+		orm.Exec(`CREATE TABLE IF NOT EXISTS test_orm_k999 (
+			id BIGINT(20) NOT NULL AUTO_INCREMENT,
+			title VARCHAR(50) NULL,
+			views BIGINT(20) NULL,
+			published_at DATETIME NULL,
+			PRIMARY KEY (id))`)
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_k999")
+
+		title := "title"
+		now := time.Now().Truncate(time.Second)
+		if err := orm.InsertBatch([]interface{}{
+			&TestOrmK999{},
+			&TestOrmK999{Title: &title, Views: &views, PublishedAt: &now},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var list []*TestOrmK999
+		if err := orm.Select(&list, "select * from test_orm_k999 order by id"); err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].Title != nil || list[0].Views != nil || list[0].PublishedAt != nil {
+			t.Fatal("NULL should be scanned into nil", list)
+		}
+		if *list[1].Title != title || *list[1].Views != views || !list[1].PublishedAt.Equal(now) {
+			t.Fatal("failed to scan into pointer fields", list[1])
+		}
+
+		*list[1].Views = 6
+		list[1].Title = nil
+		changed := list[1].Snapshot.changedFields(reflect.ValueOf(list[1]).Elem(), modelInfoOf(list[1]))
+		if !changed["Views"] || !changed["Title"] || changed["PublishedAt"] {
+			t.Fatal("should detect the changes of pointer fields", changed)
+		}
+		if err := orm.UpdateChanged(list[1]); err != nil {
+			t.Fatal(err)
+		}
+		var loaded TestOrmK999
+		if err := orm.SelectByPK(&loaded, list[1].Id); err != nil || loaded.Title != nil || *loaded.Views != 6 {
+			t.Fatal("failed to update pointer fields", err, loaded)
+		}
+	})
+}
+
+type testIP [4]byte
+
+type testIPConverter struct{}
+
+func (c testIPConverter) ToColumn(field reflect.Value) (interface{}, error) {
+	ip := field.Interface().(testIP)
+	return fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], ip[3]), nil
+}
+
+func (c testIPConverter) FromColumn(column interface{}, field reflect.Value) error {
+	var ip testIP
+	s, _ := columnString(column)
+	if _, err := fmt.Sscanf(s, "%d.%d.%d.%d", &ip[0], &ip[1], &ip[2], &ip[3]); err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(ip))
+	return nil
+}
+
+type TestOrmL000 struct {
+	Snapshot
+	Id    int64             `pk:"true" ai:"true"`
+	Tags  []string          `conv:"csv"`
+	Attrs map[string]string `conv:"json"`
+	Ip    testIP
+}
+
+func TestConverter(t *testing.T) {
+	RegisterConverter(testIP{}, testIPConverter{})
+	mi := getModelInfo(reflect.TypeOf(TestOrmL000{}))
+	if mi.byName["Tags"].conv != (csvConverter{}) || mi.byName["Ip"].conv != (testIPConverter{}) {
+		t.Fatal("wrong converters", mi.byName)
+	}
+
+	obj := TestOrmL000{Tags: []string{"a", "b,c"}, Attrs: map[string]string{"k": "v"}, Ip: testIP{127, 0, 0, 1}}
+	_, _, args, _, _ := columnsByStruct(&obj)
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i], _ = arg.(driver.Valuer).Value()
+	}
+	if values[0] != `a,"b,c"` || values[1] != `{"k":"v"}` || values[2] != "127.0.0.1" {
+		t.Fatal("wrong converted values", values)
+	}
+
+	var loaded TestOrmL000
+	targets := scanTargets(reflect.ValueOf(&loaded).Elem(), mi.fieldsOf([]string{"tags", "attrs", "ip"}))
+	for i, target := range targets {
+		if err := target.(sql.Scanner).Scan([]byte(values[i].(string))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(loaded.Tags, obj.Tags) || !reflect.DeepEqual(loaded.Attrs, obj.Attrs) || loaded.Ip != obj.Ip {
+		t.Fatal("wrong scanned values", loaded)
+	}
+
+	str, ok, err := rawString(jsonConverter{}, []byte(`"raw"`))
+	if err != nil || !ok || str != "raw" {
+		t.Fatal("should convert raw string by the converter", str, ok, err)
+	}
+
+	oneTestScope(func(orm *ORM) {
+		orm.Exec(`CREATE TABLE IF NOT EXISTS test_orm_l000 (
+			id BIGINT(20) NOT NULL AUTO_INCREMENT,
+			tags VARCHAR(255) NULL,
+			attrs TEXT NULL,
+			ip VARCHAR(15) NOT NULL,
+			PRIMARY KEY (id))`)
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_l000")
+
+		if err := orm.Insert(&obj); err != nil {
+			t.Fatal(err)
+		}
+		var list []*TestOrmL000
+		if err := orm.Select(&list, "select * from test_orm_l000"); err != nil || len(list) != 1 {
+			t.Fatal("failed to select", err, list)
+		}
+		if !reflect.DeepEqual(list[0].Attrs, obj.Attrs) || list[0].Ip != obj.Ip {
+			t.Fatal("failed to convert the selected values", list[0])
+		}
+
+		list[0].Attrs["k"] = "changed"
+		if err := orm.UpdateChanged(list[0]); err != nil {
+			t.Fatal(err)
+		}
+		set, err := orm.SelectRawSet("select attrs from test_orm_l000")
+		if err != nil || set[0]["Attrs"] != `{"k":"changed"}` {
+			t.Fatal("should update the in-place modified map", err, set)
+		}
+	})
+}
+
+type testProfile struct {
+	Nickname string   `json:"nickname"`
+	Hobbies  []string `json:"hobbies,omitempty"`
+}
+
+type TestOrmM111 struct {
+	Id      int64        `pk:"true" ai:"true"`
+	Profile testProfile  `conv:"json"`
+	Extra   *testProfile `conv:"json"`
+	Raw     json.RawMessage
+}
+
+func TestJSONColumn(t *testing.T) {
+	mi := getModelInfo(reflect.TypeOf(TestOrmM111{}))
+	if mi.byName["Raw"].conv != (rawJSONConverter{}) {
+		t.Fatal("json.RawMessage should be converted by default")
+	}
+	var raw json.RawMessage
+	if v, _ := (rawJSONConverter{}).ToColumn(reflect.ValueOf(raw)); v != nil {
+		t.Fatal("empty json.RawMessage should be NULL", v)
+	}
+	if err := (rawJSONConverter{}).FromColumn([]byte(`{"a":1}`), reflect.ValueOf(&raw).Elem()); err != nil || string(raw) != `{"a":1}` {
+		t.Fatal("failed to scan json.RawMessage", err, raw)
+	}
+
+	oneTestScope(func(orm *ORM) {
+		orm.Exec(`CREATE TABLE IF NOT EXISTS test_orm_m111 (
+			id BIGINT(20) NOT NULL AUTO_INCREMENT,
+			profile JSON NOT NULL,
+			extra JSON NULL,
+			raw JSON NULL,
+			PRIMARY KEY (id))`)
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_m111")
+
+		obj := TestOrmM111{Profile: testProfile{Nickname: "nick", Hobbies: []string{"go"}}, Raw: json.RawMessage(`[1, 2]`)}
+		if err := orm.Insert(&obj); err != nil {
+			t.Fatal(err)
+		}
+		var loaded TestOrmM111
+		if err := orm.SelectByPK(&loaded, obj.Id); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded.Profile, obj.Profile) || loaded.Extra != nil || string(loaded.Raw) != "[1, 2]" {
+			t.Fatal("failed to load json columns", loaded)
+		}
+		nickname, err := orm.SelectStr("select profile->>'$.nickname' from test_orm_m111")
+		if err != nil || nickname != "nick" {
+			t.Fatal("should be stored as json", err, nickname)
+		}
+	})
+}
+
+type TestOrmN222 struct {
+	Timestamps
+	Id       int64  `pk:"true" ai:"true"`
+	Slug     string `unique:"true"`
+	UserId   int64  `index:"idx_user_state"`
+	State    int8   `index:"idx_user_state"`
+	Price    *float64
+	Tags     []string `conv:"csv"`
+	Disabled bool
+}
+
+func TestTableDiff(t *testing.T) {
+	model, err := getModelInfo(reflect.TypeOf(TestOrmN222{})).schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE TABLE `test_orm_n222` (\n" +
+		"  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `slug` varchar(255) NOT NULL,\n" +
+		"  `user_id` bigint NOT NULL,\n" +
+		"  `state` tinyint NOT NULL,\n" +
+		"  `price` double NULL,\n" +
+		"  `tags` text NULL,\n" +
+		"  `disabled` tinyint(1) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `slug` (`slug`),\n" +
+		"  KEY `idx_user_state` (`user_id`, `state`)\n)"
+	if got := diffTable(model, nil).Statements(); len(got) != 1 || got[0] != want {
+		t.Fatal("unexpected create table", got)
+	}
+
+	db := &tableSchema{name: "test_orm_n222", indexes: []*indexSchema{
+		{name: "PRIMARY", unique: true, columns: []string{"id"}},
+		{name: "idx_user_state", columns: []string{"user_id"}},
+		{name: "idx_old", columns: []string{"old"}},
+	}}
+	for _, cs := range model.columns {
+		c := *cs
+		db.columns = append(db.columns, &c)
+	}
+	db.columns[3].typ, db.columns[3].dataType = "varchar(64)", "varchar"
+	db.columns[4].typ = "int"
+	db.columns[5].nullable = true
+	db.columns[6].typ, db.columns[6].dataType = "varchar(20)", "varchar"
+	db.columns[8].typ = "tinyint"
+	// the stale default of database is dropped
+	db.columns[8].dflt = sql.NullString{String: "0", Valid: true}
+	db.columns = append(db.columns[:7], db.columns[8:]...)
+	db.columns = append(db.columns, &columnSchema{name: "old", typ: "int", dataType: "int"})
+	d := diffTable(model, db)
+	if len(d.Columns) != 5 || len(d.Indexes) != 3 {
+		t.Fatal("unexpected diff", d)
+	}
+	want = "ALTER TABLE `test_orm_n222`\n" +
+		"  DROP INDEX `idx_user_state`,\n" +
+		"  DROP INDEX `idx_old`,\n" +
+		"  DROP COLUMN `old`,\n" +
+		"  MODIFY COLUMN `state` tinyint NOT NULL,\n" +
+		"  MODIFY COLUMN `price` double NULL,\n" +
+		"  ADD COLUMN `tags` text NULL AFTER `price`,\n" +
+		"  MODIFY COLUMN `disabled` tinyint(1) NOT NULL,\n" +
+		"  ADD KEY `idx_user_state` (`user_id`, `state`),\n" +
+		"  ADD UNIQUE KEY `slug` (`slug`)"
+	if got := d.Statements(); len(got) != 1 || got[0] != want {
+		t.Fatal("unexpected alter table", got)
+	}
+	if !diffTable(model, model).Empty() {
+		t.Fatal("the model should match itself")
+	}
+	// the auto timestamp of database is kept for the time field without default
+	published := &columnSchema{name: "published_at", typ: "datetime", dataType: "datetime"}
+	dbPublished := &columnSchema{name: "published_at", typ: "datetime", dataType: "datetime",
+		dflt: sql.NullString{String: "CURRENT_TIMESTAMP", Valid: true}}
+	if !published.defaultMatches(dbPublished) {
+		t.Fatal("the auto timestamp should match the time field without default")
+	}
+
+	oneTestScope(func(orm *ORM) {
+		orm.Exec(`CREATE TABLE IF NOT EXISTS test_orm_n222 (
+			id INT(11) NOT NULL AUTO_INCREMENT,
+			slug VARCHAR(64) NOT NULL,
+			user_id BIGINT(20) NOT NULL,
+			price DECIMAL(10, 2) NOT NULL,
+			disabled TINYINT(1) NOT NULL DEFAULT 0,
+			old INT(11) NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (id),
+			KEY idx_user_state (user_id))`)
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_n222")
+		orm.AddTable(TestOrmN222{})
+
+		diffs, err := orm.DiffTables()
+		if err != nil || len(diffs) != 1 || diffs[0].Table != "test_orm_n222" {
+			t.Fatal("failed to diff tables", diffs, err)
+		}
+		for _, stmt := range diffs[0].Statements() {
+			if _, err := orm.Exec(stmt); err != nil {
+				t.Fatal(stmt, err)
+			}
+		}
+		d, err := orm.DiffTable(TestOrmN222{})
+		if err != nil || !d.Empty() {
+			t.Fatal("the table should converge to the model", d, err)
+		}
+	})
+}
+
+type TestOrmO333 struct {
+	Timestamps
+	Id      int64   `pk:"true" ai:"true"`
+	Title   string  `size:"64" default:"untitled"`
+	Price   float64 `size:"10,2" default:"0"`
+	Digest  []byte  `size:"32" unique:"uk_digest"`
+	Views   int     `default:"0" index:"true"`
+	Comment *string
+	Public  bool `default:"true"`
+}
+
+func TestCreateTable(t *testing.T) {
+	model, err := getModelInfo(reflect.TypeOf(TestOrmO333{})).schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE TABLE IF NOT EXISTS `test_orm_o333` (\n" +
+		"  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `title` varchar(64) NOT NULL DEFAULT 'untitled',\n" +
+		"  `price` decimal(10,2) NOT NULL DEFAULT '0',\n" +
+		"  `digest` varbinary(32) NULL,\n" +
+		"  `views` int NOT NULL DEFAULT '0',\n" +
+		"  `comment` varchar(255) NULL,\n" +
+		"  `public` tinyint(1) NOT NULL DEFAULT '1',\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `uk_digest` (`digest`),\n" +
+		"  KEY `views` (`views`)\n)"
+	if got := createTableSQL(model, true); got != want {
+		t.Fatal("unexpected create table", got)
+	}
+	for _, typ := range []interface{}{
+		struct {
+			Id int64 `size:"10"`
+		}{},
+		struct {
+			Name string `size:"10,2"`
+		}{},
+		struct {
+			Attrs map[string]string
+		}{},
+		struct {
+			Data []byte `default:"x"`
+		}{},
+		struct {
+			Attrs map[string]string `conv:"json" default:"{}"`
+		}{},
+		struct {
+			Tags []string `conv:"csv" default:"a,b"`
+		}{},
+	} {
+		if _, err := getModelInfo(reflect.TypeOf(typ)).schema(); !errors.Is(err, ErrUnsupportedType) {
+			t.Fatal("should not support the field", typ, err)
+		}
+	}
+	if _, err := getModelInfo(reflect.TypeOf(struct {
+		Public bool `default:"yes"`
+	}{})).schema(); err == nil {
+		t.Fatal("should not support the invalid default of bool")
+	}
+
+	oneTestScope(func(orm *ORM) {
+		orm.AddTable(TestOrmO333{})
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_o333")
+		if err := orm.CreateTables(); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.CreateTable(TestOrmO333{}); err != nil {
+			t.Fatal("should skip the existing table", err)
+		}
+		if d, err := orm.DiffTable(TestOrmO333{}); err != nil || !d.Empty() {
+			t.Fatal("the created table should match the model", d, err)
+		}
+		if _, err := orm.Exec("INSERT INTO test_orm_o333 (digest) VALUES (?)", []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		var obj TestOrmO333
+		if err := orm.SelectOne(&obj, "SELECT * FROM test_orm_o333"); err != nil || obj.Title != "untitled" ||
+			obj.Views != 0 || obj.Comment != nil || obj.CreatedAt.IsZero() {
+			t.Fatal("failed to insert with default values", obj, err)
+		}
+	})
+}
+
+type TestOrmP444 struct {
+	TestOrmPId int64 `pk:"true" ai:"true"`
+	Title      string
+	Comments   []*TestOrmQ555 `or:"has_many" table:"test_orm_q555"`
+}
+
+type TestOrmQ555 struct {
+	TestOrmQId int64 `pk:"true" ai:"true"`
+	TestOrmPId int64
+	TestOrmRId *int64
+	Body       string
+	User       *TestOrmR666 `or:"belongs_to" table:"test_orm_r666"`
+}
+
+type TestOrmR666 struct {
+	TestOrmRId int64 `pk:"true" ai:"true"`
+	Name       string
+}
+
+func TestPreload(t *testing.T) {
+	tree := newPreloadTree([]string{"Comments.User", "Comments", " Author "})
+	if len(tree) != 2 || len(tree["Comments"]) != 1 || tree["Comments"]["User"] == nil || tree["Author"] == nil {
+		t.Fatal("unexpected preload tree", tree)
+	}
+
+	oneTestScope(func(orm *ORM) {
+		for _, m := range []interface{}{TestOrmP444{}, TestOrmQ555{}, TestOrmR666{}} {
+			if err := orm.CreateTable(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_p444, test_orm_q555, test_orm_r666")
+
+		user := &TestOrmR666{Name: "user"}
+		p1, p2 := &TestOrmP444{Title: "p1"}, &TestOrmP444{Title: "p2"}
+		for _, m := range []interface{}{user, p1, p2} {
+			if err := orm.Insert(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, c := range []*TestOrmQ555{
+			{TestOrmPId: p1.TestOrmPId, TestOrmRId: &user.TestOrmRId, Body: "c1"},
+			{TestOrmPId: p1.TestOrmPId, Body: "c2"},
+			{TestOrmPId: p2.TestOrmPId, TestOrmRId: &user.TestOrmRId, Body: "c3"},
+		} {
+			if err := orm.Insert(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var posts []*TestOrmP444
+		if err := orm.Select(&posts, "SELECT * FROM test_orm_p444 ORDER BY test_orm_p_id"); err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 2 || len(posts[0].Comments) != 2 || len(posts[1].Comments) != 1 || posts[0].Comments[0].User != nil {
+			t.Fatal("should only load the relations of posts by default", posts)
+		}
+
+		posts = nil
+		if err := orm.Query(&posts).Preload("Comments.User").OrderBy("test_orm_p_id").All(); err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 2 || len(posts[0].Comments) != 2 || len(posts[1].Comments) != 1 {
+			t.Fatal("failed to preload comments", posts)
+		}
+		for _, c := range append(posts[0].Comments, posts[1].Comments...) {
+			if (c.Body == "c2") != (c.User == nil) || (c.User != nil && c.User.Name != "user") {
+				t.Fatal("failed to preload the user of comment", c, c.User)
+			}
+		}
+
+		posts = nil
+		if err := orm.Query(&posts).Preload().All(); err != nil || len(posts) != 2 || posts[0].Comments != nil {
+			t.Fatal("should skip all the relations", posts, err)
+		}
+
+		var post TestOrmP444
+		if err := orm.Query(&post).Where("title = ?", "p2").Preload().One(); err != nil || post.Comments != nil {
+			t.Fatal("should skip all the relations", post, err)
+		}
+		if err := orm.Preload(&post, "Comments.User"); err != nil || len(post.Comments) != 1 || post.Comments[0].User == nil {
+			t.Fatal("failed to preload the selected post", post, err)
+		}
+		if err := orm.Preload(&post, "Comments.Post"); err == nil {
+			t.Fatal("should fail to preload unknown relation")
+		}
+	})
+}
+
+type TestOrmS777 struct {
+	TestOrmSId int64 `pk:"true" ai:"true"`
+	AuthorId   int64
+	Title      string
+	Author     *TestOrmT888 `or:"belongs_to" table:"test_orm_t888" fk:"author_id"`
+}
+
+type TestOrmT888 struct {
+	TestOrmTId int64 `pk:"true" ai:"true"`
+	Name       string
+	Articles   []*TestOrmS777 `or:"has_many" table:"test_orm_s777" fk:"author_id" ref:"test_orm_t_id"`
+}
+
+type TestOrmU999 struct {
+	TestOrmUId int64        `pk:"true" ai:"true"`
+	Writer     *TestOrmT888 `or:"belongs_to" table:"test_orm_t888" fk:"writer_id"`
+}
+
+// The relations without table tag, which are the tables of the related models
+type TestOrmX333 struct {
+	Timestamps
+	TestId      int64 `pk:"true" ai:"true"`
+	OtherId     int64
+	Description string
+	Name        *string
+	StartDate   *time.Time
+	EndDate     *time.Time
+	TestOrmDId  int64
+	OrmCs       []*TestOrmC111 `or:"has_many"`
+	OrmD        *TestOrmD222   `or:"belongs_to"`
+}
+
+func (o TestOrmX333) TableName() string {
+	return "test_orm_a123"
+}
+
+func TestRelationKeys(t *testing.T) {
+	var mfErr *MissingFieldError
+	if err := NewORM().AddTable(TestOrmU999{}); !errors.As(err, &mfErr) || mfErr.Column != "writer_id" {
+		t.Fatal("should be MissingFieldError of fk", err)
+	}
+	for _, s := range []interface{}{
+		struct {
+			TestOrmSId int64          `pk:"true"`
+			Articles   []*TestOrmS777 `or:"has_many" fk:"author_id,title"`
+		}{},
+		struct {
+			TestId int64       `pk:"true"`
+			OrmB   TestOrmB999 `or:"has_one"`
+		}{},
+		struct {
+			TestId int64         `pk:"true"`
+			OrmCs  []TestOrmC111 `or:"has_many"`
+		}{},
+		struct {
+			TestId int64  `pk:"true"`
+			OrmCs  *int64 `or:"has_one"`
+		}{},
+		struct {
+			TestId int64        `pk:"true"`
+			OrmCs  *TestOrmC111 `or:"many_to_many"`
+		}{},
+	} {
+		o := NewORM()
+		if err := o.AddTable(s); err == nil || o.tables[getTableName(s)] != nil {
+			t.Fatal("should not add the table of invalid relation", s)
+		}
+	}
+
+	var invalid struct {
+		TestOrmAId int64       `pk:"true"`
+		OrmB       TestOrmB999 `or:"has_one"`
+	}
+	if err := preloadHolder(nil, &invalid, nil); err == nil {
+		t.Fatal("the query should return the error of invalid relation when loading it")
+	}
+
+	eachTestScope(t, func(t *testing.T, orm *ORM) {
+		if err := orm.AddTable(TestOrmX333{}); err != nil {
+			t.Fatal(err)
+		}
+		d := &TestOrmD222{Name: "d"}
+		if err := orm.Insert(d); err != nil {
+			t.Fatal(err)
+		}
+		obj := &TestOrmX333{OtherId: 1, Description: "x", TestOrmDId: d.TestOrmDId}
+		if err := orm.Insert(obj); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.Insert(&TestOrmC111{TestId: obj.TestId, Name: "c"}); err != nil {
+			t.Fatal(err)
+		}
+		var loaded TestOrmX333
+		if err := orm.SelectByPK(&loaded, obj.TestId); err != nil {
+			t.Fatal(err)
+		}
+		if loaded.OrmD == nil || loaded.OrmD.Name != "d" || len(loaded.OrmCs) != 1 || loaded.OrmCs[0].Name != "c" {
+			t.Fatal("failed to load the relations by the tables of related models", loaded)
+		}
+	})
+
+	oneTestScope(func(orm *ORM) {
+		orm.AddTable(TestOrmS777{})
+		orm.AddTable(TestOrmT888{})
+		for _, m := range []interface{}{TestOrmS777{}, TestOrmT888{}} {
+			if err := orm.CreateTable(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_s777, test_orm_t888")
+
+		author := &TestOrmT888{Name: "author"}
+		if err := orm.Insert(author); err != nil {
+			t.Fatal(err)
+		}
+		for _, title := range []string{"a1", "a2"} {
+			if err := orm.Insert(&TestOrmS777{AuthorId: author.TestOrmTId, Title: title}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var article TestOrmS777
+		if err := orm.SelectOne(&article, "SELECT * FROM test_orm_s777 WHERE title = ?", "a2"); err != nil {
+			t.Fatal(err)
+		}
+		if article.Author == nil || article.Author.Name != "author" {
+			t.Fatal("failed to load belongs_to by fk", article.Author)
+		}
+
+		var authors []*TestOrmT888
+		if err := orm.Select(&authors, "SELECT * FROM test_orm_t888"); err != nil {
+			t.Fatal(err)
+		}
+		if len(authors) != 1 || len(authors[0].Articles) != 2 || authors[0].Articles[0].AuthorId != author.TestOrmTId {
+			t.Fatal("failed to load has_many by fk", authors)
+		}
+	})
+}
+
+type TestOrmV111 struct {
+	TestOrmVId int64 `pk:"true" ai:"true"`
+	TestOrmRId int32
+	User       *TestOrmR666 `or:"belongs_to" table:"test_orm_r666"`
+}
+
+func TestRelationKeyTypes(t *testing.T) {
+	if tupleKey([]interface{}{1, uint8(2), []byte("a")}) != tupleKey([]interface{}{int64(1), uint64(2), "a"}) {
+		t.Fatal("the key values of different types should match")
+	}
+	if tupleKey([]interface{}{1}) == tupleKey([]interface{}{"1"}) {
+		t.Fatal("the key values of int and string should not match")
+	}
+
+	oneTestScope(func(orm *ORM) {
+		for _, m := range []interface{}{TestOrmV111{}, TestOrmR666{}} {
+			if err := orm.CreateTable(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer orm.Exec("DROP TABLE IF EXISTS test_orm_v111, test_orm_r666")
+
+		user := &TestOrmR666{Name: "user"}
+		if err := orm.Insert(user); err != nil {
+			t.Fatal(err)
+		}
+		if err := orm.Insert(&TestOrmV111{TestOrmRId: int32(user.TestOrmRId)}); err != nil {
+			t.Fatal(err)
+		}
+		var list []*TestOrmV111
+		if err := orm.Select(&list, "SELECT * FROM test_orm_v111"); err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].User == nil || list[0].User.Name != "user" {
+			t.Fatal("failed to load belongs_to by the fk of int32", list)
+		}
+	})
+}
@@ -0,0 +1,193 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// preloadTree is the relations to be loaded by their field names, i.e. Comments.User is {Comments: {User: {}}}
+type preloadTree map[string]preloadTree
+
+func newPreloadTree(paths []string) preloadTree {
+	tree := preloadTree{}
+	for _, path := range paths {
+		node := tree
+		for _, name := range strings.Split(path, ".") {
+			name = strings.TrimSpace(name)
+			if node[name] == nil {
+				node[name] = preloadTree{}
+			}
+			node = node[name]
+		}
+	}
+	return tree
+}
+
+// All the relations of the model without the nested ones, which are loaded by default
+func (mi *modelInfo) relationTree() preloadTree {
+	tree := make(preloadTree, len(mi.relations))
+	for _, orCol := range mi.relations {
+		tree[orCol.fieldName] = preloadTree{}
+	}
+	return tree
+}
+
+func (mi *modelInfo) relation(name string) *orColumn {
+	for _, orCol := range mi.relations {
+		if orCol.fieldName == name {
+			return orCol
+		}
+	}
+	return nil
+}
+
+// The struct values of the holder, which could be *T or *[]*T, the nil elements are skipped
+func holderValues(s interface{}) []reflect.Value {
+	v := reflect.ValueOf(s).Elem()
+	if v.Kind() == reflect.Struct {
+		return []reflect.Value{v}
+	}
+	ret := make([]reflect.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		e := v.Index(i)
+		if e.Kind() == reflect.Ptr {
+			if e.IsNil() {
+				continue
+			}
+			e = e.Elem()
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+// Load the relations of the holder, all the relations of the model without the nested ones if tree is nil
+func preloadHolder(tdx Tdx, s interface{}, tree preloadTree) error {
+	mi := modelInfoOf(s)
+	if tree == nil {
+		tree = mi.relationTree()
+	}
+	if len(tree) == 0 {
+		return nil
+	}
+	return preload(tdx, mi, holderValues(s), tree)
+}
+
+// Load the relations of the tree into the values of the model, with one IN query for each relation,
+// and then the nested relations into the loaded values level by level
+func preload(tdx Tdx, mi *modelInfo, values []reflect.Value, tree preloadTree) error {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		orCol := mi.relation(name)
+		if orCol == nil {
+			return fmt.Errorf("%s does not have relation %s", mi.typ.Name(), name)
+		}
+		loaded, err := loadRelation(tdx, mi, orCol, values)
+		if err != nil {
+			return err
+		}
+		if err := preload(tdx, getModelInfo(orCol.orType), loaded, tree[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The key columns of the relation on the model and on the related model. The foreign key columns of tag fk
+// reference the columns of tag ref, which are on the related model for belongs_to, or on the model for has_one
+// and has_many. By default ref is the primary key columns, and fk is the same as ref, e.g.
+//
+//	Author *User `or:"belongs_to" fk:"author_id"`
+func (orCol *orColumn) keyColumns(mi *modelInfo) ([]string, []string, error) {
+	if orCol.err != nil {
+		return nil, nil, fmt.Errorf("relation %s of %s: %w", orCol.fieldName, mi.typ.Name(), orCol.err)
+	}
+	refMi := mi
+	if orCol.or == TAG_BELONGS_TO {
+		refMi = getModelInfo(orCol.orType)
+	}
+	refs := orCol.ref
+	if len(refs) == 0 {
+		refs = refMi.pkColumns()
+		if len(refs) == 0 {
+			return nil, nil, fmt.Errorf("%s %w for %s", refMi.table, ErrNoPrimaryKey, orCol.or)
+		}
+	}
+	fks := orCol.fk
+	if len(fks) == 0 {
+		fks = refs
+	}
+	if len(fks) != len(refs) {
+		return nil, nil, fmt.Errorf("relation %s of %s has %d fk columns, but %d ref columns",
+			orCol.fieldName, mi.typ.Name(), len(fks), len(refs))
+	}
+	if orCol.or == TAG_BELONGS_TO {
+		return fks, refs, nil
+	}
+	return refs, fks, nil
+}
+
+// Load the relation of all the values by one query, returns the loaded related values
+func loadRelation(tdx Tdx, mi *modelInfo, orCol *orColumn, values []reflect.Value) ([]reflect.Value, error) {
+	cols, refCols, err := orCol.keyColumns(mi)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([][]interface{}, 0, len(values))
+	byKey := map[string][]reflect.Value{}
+	for _, v := range values {
+		field := v.FieldByName(orCol.fieldName)
+		field.Set(reflect.Zero(field.Type()))
+		key, err := mi.columnValues(v, cols)
+		if err != nil {
+			return nil, err
+		}
+		if hasNilValue(key) {
+			// NULL matches nothing
+			continue
+		}
+		k := tupleKey(key)
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[k] = append(byKey[k], v)
+	}
+	loaded := make([]reflect.Value, 0)
+	if len(keys) == 0 {
+		return loaded, nil
+	}
+	orMi := getModelInfo(orCol.orType)
+	err = processOrManyRelation(tdx, orCol, refCols, keys, func(orValue reflect.Value) error {
+		key, err := orMi.columnValues(orValue.Elem(), refCols)
+		if err != nil {
+			return err
+		}
+		for _, v := range byKey[tupleKey(key)] {
+			field := v.FieldByName(orCol.fieldName)
+			if orCol.or == TAG_HAS_MANY {
+				field.Set(reflect.Append(field, orValue))
+			} else if field.IsNil() {
+				// the first row of has_one
+				field.Set(orValue)
+			}
+		}
+		loaded = append(loaded, orValue.Elem())
+		return nil
+	})
+	return loaded, err
+}
+
+func hasNilValue(values []interface{}) bool {
+	for _, v := range values {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
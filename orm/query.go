@@ -0,0 +1,319 @@
+package orm
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// QueryBuilder composes a select query on the table of the holder, i.e.
+//
+//	var articles []*Article
+//	err := o.Query(&articles).Where("user_id = ?", 5).OrderBy("created_at DESC").Limit(20).Offset(40).All()
+//
+// The table name is derived from the holder type, and the column names given to Columns/OrderBy are quoted.
+// The conditions given to Where/And/Or are raw SQL with `?` placeholders.
+type QueryBuilder struct {
+	tdx     Tdx
+	holder  interface{}
+	table   string
+	columns []string
+	where   string
+	args    []interface{}
+	orderBy []string
+	limit   int64
+	offset  int64
+	// nil to load all the relations without nested ones
+	preloads []string
+	// error of the invalid filter, which is returned by All/One/Count/Delete
+	err error
+}
+
+func newQueryBuilder(tdx Tdx, s interface{}) *QueryBuilder {
+	return &QueryBuilder{
+		tdx:    tdx,
+		holder: s,
+		table:  getTableName(s),
+		limit:  -1,
+	}
+}
+
+// The struct type of the holder, which could be *T or *[]*T, the slice of struct values is unsupported by the queries
+func holderType(s interface{}) reflect.Type {
+	t := reflect.TypeOf(s)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Quote the identifier with backtick, table qualified name like article.user_id is quoted separately
+func quoteIdentifier(name string) string {
+	if strings.HasPrefix(name, "`") || name == "*" {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		if p != "*" {
+			parts[i] = "`" + strings.Replace(p, "`", "``", -1) + "`"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// Quote the column of order by expression, i.e. created_at DESC -> `created_at` DESC
+func quoteOrderBy(expr string) string {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 || len(fields) > 2 || strings.ContainsAny(fields[0], "()`") {
+		return expr
+	}
+	if len(fields) == 2 {
+		dir := strings.ToUpper(fields[1])
+		if dir != "ASC" && dir != "DESC" {
+			return expr
+		}
+		return quoteIdentifier(fields[0]) + " " + dir
+	}
+	return quoteIdentifier(fields[0])
+}
+
+// Only select the given columns instead of *
+func (q *QueryBuilder) Columns(cols ...string) *QueryBuilder {
+	q.columns = append(q.columns, cols...)
+	return q
+}
+
+// Set the where condition, which replaces the previous conditions
+func (q *QueryBuilder) Where(cond string, args ...interface{}) *QueryBuilder {
+	q.where = cond
+	q.args = args
+	return q
+}
+
+// Set the where condition of the filter, which replaces the previous conditions.
+// The error of the invalid filter is returned when the query is executed
+func (q *QueryBuilder) Filter(f Filter) *QueryBuilder {
+	if f.err != nil && q.err == nil {
+		q.err = f.err
+	}
+	return q.Where(f.cond, f.args...)
+}
+
+func (q *QueryBuilder) And(cond string, args ...interface{}) *QueryBuilder {
+	return q.combine("AND", cond, args)
+}
+
+func (q *QueryBuilder) Or(cond string, args ...interface{}) *QueryBuilder {
+	return q.combine("OR", cond, args)
+}
+
+func (q *QueryBuilder) combine(op string, cond string, args []interface{}) *QueryBuilder {
+	if q.where == "" {
+		return q.Where(cond, args...)
+	}
+	q.where = "(" + q.where + ") " + op + " (" + cond + ")"
+	// q.args may be the variadic args of the caller, which shouldn't be appended in place
+	newArgs := make([]interface{}, 0, len(q.args)+len(args))
+	q.args = append(append(newArgs, q.args...), args...)
+	return q
+}
+
+func (q *QueryBuilder) OrderBy(exprs ...string) *QueryBuilder {
+	q.orderBy = append(q.orderBy, exprs...)
+	return q
+}
+
+func (q *QueryBuilder) Limit(n int64) *QueryBuilder {
+	q.limit = n
+	return q
+}
+
+func (q *QueryBuilder) Offset(n int64) *QueryBuilder {
+	q.offset = n
+	return q
+}
+
+// Only load the relations of the paths, e.g. Preload("Comments.User", "Author") loads Comments, their User and
+// Author, with one IN query for each relation level by level. The other relations are skipped, and Preload()
+// without path skips all of them. By default all the relations of the holder are loaded without nested ones
+func (q *QueryBuilder) Preload(paths ...string) *QueryBuilder {
+	q.preloads = append(append(make([]string, 0, len(q.preloads)+len(paths)), q.preloads...), paths...)
+	return q
+}
+
+// The relations to be loaded, nil for the default ones
+func (q *QueryBuilder) preloadTree() preloadTree {
+	if q.preloads == nil {
+		return nil
+	}
+	return newPreloadTree(q.preloads)
+}
+
+// Build the query with the given select expression, and the args for the placeholders
+func (q *QueryBuilder) build(selectExpr string, withPaging bool) (string, []interface{}) {
+	buff := bytes.Buffer{}
+	buff.WriteString("SELECT ")
+	buff.WriteString(selectExpr)
+	buff.WriteString(" FROM ")
+	buff.WriteString(quoteIdentifier(q.table))
+	if q.where != "" {
+		buff.WriteString(" WHERE ")
+		buff.WriteString(q.where)
+	}
+	if !withPaging {
+		return buff.String(), q.args
+	}
+	if len(q.orderBy) > 0 {
+		orders := make([]string, len(q.orderBy))
+		for i, o := range q.orderBy {
+			orders[i] = quoteOrderBy(o)
+		}
+		buff.WriteString(" ORDER BY ")
+		buff.WriteString(strings.Join(orders, ", "))
+	}
+	buff.WriteString(dialectOf(q.tdx).LimitOffset(q.limit, q.offset))
+	return buff.String(), q.args
+}
+
+func (q *QueryBuilder) selectExpr() string {
+	if len(q.columns) == 0 {
+		return "*"
+	}
+	cols := make([]string, len(q.columns))
+	for i, c := range q.columns {
+		cols[i] = quoteIdentifier(c)
+	}
+	return strings.Join(cols, ", ")
+}
+
+// The SQL and args to be executed by All
+func (q *QueryBuilder) SQL() (string, []interface{}) {
+	return q.build(q.selectExpr(), true)
+}
+
+// Select all the matched rows into the holder, which should be a pointer of slice
+func (q *QueryBuilder) All() error {
+	if q.err != nil {
+		return q.err
+	}
+	if reflect.TypeOf(q.holder).Elem().Kind() != reflect.Slice {
+		return errors.New("can not select all into a non-pointer slice")
+	}
+	query, args := q.SQL()
+	return selectManyInternal(q.tdx, q.holder, q.preloadTree(), query, args...)
+}
+
+// Select the first matched row into the holder, which should be a pointer of struct.
+// sql.ErrNoRows is returned if nothing matched
+func (q *QueryBuilder) One() error {
+	if q.err != nil {
+		return q.err
+	}
+	if reflect.TypeOf(q.holder).Elem().Kind() != reflect.Struct {
+		return errors.New("can not select one into a non-pointer struct")
+	}
+	limit := q.limit
+	q.limit = 1
+	query, args := q.SQL()
+	q.limit = limit
+	return selectOneWith(q.tdx, q.holder, q.preloadTree(), query, args...)
+}
+
+// Count the matched rows, limit and offset are not applied
+func (q *QueryBuilder) Count() (int64, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+	query, args := q.build("COUNT(*)", false)
+	return selectInt(q.tdx, query, args...)
+}
+
+// Delete all the matched rows, order by, limit and offset are not applied
+func (q *QueryBuilder) Delete() (int64, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+	query := "DELETE FROM " + quoteIdentifier(q.table)
+	if q.where != "" {
+		query += " WHERE " + q.where
+	}
+	ret, err := q.tdx.Exec(query, q.args...)
+	if err != nil {
+		return 0, err
+	}
+	return ret.RowsAffected()
+}
+
+var filterOps = map[string]bool{
+	"=": true, "!=": true, "<>": true, ">": true, ">=": true, "<": true, "<=": true,
+	"LIKE": true, "NOT LIKE": true, "IN": true, "NOT IN": true,
+}
+
+// Filter is a condition on columns which can be composed by And/Or, and applied to QueryBuilder by Filter
+type Filter struct {
+	cond string
+	args []interface{}
+	// the first error of the composed filters
+	err error
+}
+
+// Filter on a column, op is one of =, !=, <>, >, >=, <, <=, like, not like, in, not in.
+// The value of in/not in should be a slice, otherwise the filter is invalid with the error, see Err
+func NewFilter(col string, op string, value interface{}) Filter {
+	op = strings.ToUpper(strings.TrimSpace(op))
+	if !filterOps[op] {
+		return Filter{cond: "1 = 0", err: errors.New("unsupported filter op: " + op)}
+	}
+	if op != "IN" && op != "NOT IN" {
+		return Filter{cond: quoteIdentifier(col) + " " + op + " ?", args: []interface{}{value}}
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return Filter{cond: "1 = 0", err: errors.New("value of filter op " + op + " should be slice")}
+	}
+	if v.Len() == 0 {
+		// nothing is in an empty set
+		if op == "IN" {
+			return Filter{cond: "1 = 0"}
+		}
+		return Filter{cond: "1 = 1"}
+	}
+	args := make([]interface{}, v.Len())
+	for i := range args {
+		args[i] = v.Index(i).Interface()
+	}
+	return Filter{
+		cond: quoteIdentifier(col) + " " + op + " (" + strings.Repeat(",?", len(args))[1:] + ")",
+		args: args,
+	}
+}
+
+func (f Filter) And(other Filter) Filter {
+	return f.combine("AND", other)
+}
+
+func (f Filter) Or(other Filter) Filter {
+	return f.combine("OR", other)
+}
+
+func (f Filter) combine(op string, other Filter) Filter {
+	args := make([]interface{}, 0, len(f.args)+len(other.args))
+	args = append(append(args, f.args...), other.args...)
+	err := f.err
+	if err == nil {
+		err = other.err
+	}
+	return Filter{cond: "(" + f.cond + ") " + op + " (" + other.cond + ")", args: args, err: err}
+}
+
+// The where condition and args of the filter, i.e. cond, args := filter.Where(); q.Where(cond, args...),
+// which matches nothing if the filter is invalid. QueryBuilder.Filter is preferred as it returns the error
+func (f Filter) Where() (string, []interface{}) {
+	return f.cond, f.args
+}
+
+// The error of the invalid filter, i.e. unsupported op, nil if it's valid
+func (f Filter) Err() error {
+	return f.err
+}
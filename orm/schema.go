@@ -0,0 +1,571 @@
+package orm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// columnSchema is the definition of a column, which is derived from the model field, or loaded from database
+type columnSchema struct {
+	name string
+	// column type in lower case, e.g. bigint unsigned and varchar(255), the display width of integer is omitted
+	typ      string
+	dataType string
+	// the type is given by the size tag, which is compared exactly
+	exact    bool
+	nullable bool
+	// the nullability is not determined by the field, e.g. []byte whose nil is NULL
+	anyNull  bool
+	ai       bool
+	dflt     sql.NullString
+	onUpdate string
+}
+
+// indexSchema is an index of the table, PRIMARY for the primary key
+type indexSchema struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+type tableSchema struct {
+	name    string
+	columns []*columnSchema
+	indexes []*indexSchema
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+	sqlNullTypes = map[reflect.Type]string{
+		reflect.TypeOf(sql.NullString{}):  "varchar(255)",
+		reflect.TypeOf(sql.NullInt64{}):   "bigint",
+		reflect.TypeOf(sql.NullInt32{}):   "int",
+		reflect.TypeOf(sql.NullInt16{}):   "smallint",
+		reflect.TypeOf(sql.NullByte{}):    "tinyint unsigned",
+		reflect.TypeOf(sql.NullFloat64{}): "double",
+		reflect.TypeOf(sql.NullBool{}):    "tinyint(1)",
+		reflect.TypeOf(sql.NullTime{}):    "datetime",
+	}
+	displayWidthReg = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\(\d+\)`)
+	sizeReg         = regexp.MustCompile(`^\d+(,\d+)?$`)
+)
+
+// The column type of the Go type, nullable for the pointer and sql.Null* types
+func mysqlColumnType(t reflect.Type) (string, bool, error) {
+	if t.Kind() == reflect.Ptr {
+		typ, _, err := mysqlColumnType(t.Elem())
+		return typ, true, err
+	}
+	if typ, ok := sqlNullTypes[t]; ok {
+		return typ, true, nil
+	}
+	switch t {
+	case timeType:
+		return "datetime", false, nil
+	case rawJSONType:
+		return "json", true, nil
+	}
+	unsigned := ""
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		unsigned = " unsigned"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "tinyint(1)", false, nil
+	case reflect.Int8, reflect.Uint8:
+		return "tinyint" + unsigned, false, nil
+	case reflect.Int16, reflect.Uint16:
+		return "smallint" + unsigned, false, nil
+	case reflect.Int, reflect.Int32, reflect.Uint, reflect.Uint32:
+		return "int" + unsigned, false, nil
+	case reflect.Int64, reflect.Uint64:
+		return "bigint" + unsigned, false, nil
+	case reflect.Float32:
+		return "float", false, nil
+	case reflect.Float64:
+		return "double", false, nil
+	case reflect.String:
+		return "varchar(255)", false, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "blob", true, nil
+		}
+	}
+	return "", false, fmt.Errorf("column type of %s %w", t, ErrUnsupportedType)
+}
+
+// The column type with the size of tag, i.e. varchar(size) for string, varbinary(size) for []byte,
+// and decimal(precision,scale) for float
+func sizedColumnType(typ string, size string) (string, error) {
+	if !sizeReg.MatchString(size) {
+		return "", fmt.Errorf("invalid size %s", size)
+	}
+	decimal := strings.Contains(size, ",")
+	switch {
+	case (typ == "varchar(255)" || typ == "text") && !decimal:
+		return "varchar(" + size + ")", nil
+	case typ == "blob" && !decimal:
+		return "varbinary(" + size + ")", nil
+	case typ == "double" || typ == "float":
+		return "decimal(" + size + ")", nil
+	}
+	return "", fmt.Errorf("size of %s %w", typ, ErrUnsupportedType)
+}
+
+// The default value of the column given by tag default, which is 0 or 1 for bool, i.e. tinyint(1).
+// The text, blob and json columns can't have the default value
+func columnDefault(typ string, dflt string) (string, error) {
+	switch typ {
+	case "tinyint(1)":
+		b, err := strconv.ParseBool(dflt)
+		if err != nil {
+			return "", fmt.Errorf("invalid default %s of bool", dflt)
+		}
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	case "text", "blob", "json":
+		return "", fmt.Errorf("default of %s %w", typ, ErrUnsupportedType)
+	}
+	return dflt, nil
+}
+
+// The schema of the model table, the fields of auto update columns, i.e. tagged by ignore, are timestamps
+// with default CURRENT_TIMESTAMP, and updated_at is also on update CURRENT_TIMESTAMP.
+// The column type and default are given by the tags, e.g. `size:"64" default:"draft"`
+func (mi *modelInfo) schema() (*tableSchema, error) {
+	ts := &tableSchema{name: mi.table, columns: make([]*columnSchema, 0, len(mi.fields))}
+	byName := map[string]*indexSchema{}
+	addIndex := func(name string, unique bool, column string) {
+		if name == "true" {
+			name = column
+		}
+		idx := byName[name]
+		if idx == nil {
+			idx = &indexSchema{name: name, unique: unique}
+			byName[name] = idx
+			ts.indexes = append(ts.indexes, idx)
+		}
+		idx.columns = append(idx.columns, column)
+	}
+	for _, f := range mi.pks {
+		addIndex("PRIMARY", true, f.column)
+	}
+	for _, f := range mi.fields {
+		ft := mi.typ.FieldByIndex(f.index)
+		cs := &columnSchema{name: f.column, ai: f.ai}
+		var err error
+		switch {
+		case f.conv != nil && ft.Tag.Get("conv") == "json":
+			cs.typ, cs.anyNull = "json", true
+		case f.conv != nil && ft.Type != rawJSONType:
+			cs.typ, cs.anyNull = "text", true
+		default:
+			cs.typ, cs.nullable, err = mysqlColumnType(ft.Type)
+			cs.anyNull = ft.Type.Kind() == reflect.Slice
+		}
+		if size := ft.Tag.Get("size"); size != "" && err == nil {
+			cs.typ, err = sizedColumnType(cs.typ, size)
+			cs.exact = true
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", f.name, mi.typ.Name(), err)
+		}
+		if dflt, ok := ft.Tag.Lookup("default"); ok {
+			if dflt, err = columnDefault(cs.typ, dflt); err != nil {
+				return nil, fmt.Errorf("field %s of %s: %w", f.name, mi.typ.Name(), err)
+			}
+			cs.dflt = sql.NullString{String: dflt, Valid: true}
+		}
+		if cs.anyNull {
+			cs.nullable = true
+		}
+		if f.ignore && cs.typ == "datetime" {
+			cs.typ = "timestamp"
+			if !cs.dflt.Valid {
+				cs.dflt = sql.NullString{String: "CURRENT_TIMESTAMP", Valid: true}
+			}
+			if f.column == "updated_at" {
+				cs.onUpdate = "CURRENT_TIMESTAMP"
+			}
+		}
+		if f.pk {
+			cs.nullable = false
+		}
+		cs.dataType = strings.SplitN(strings.SplitN(cs.typ, "(", 2)[0], " ", 2)[0]
+		ts.columns = append(ts.columns, cs)
+		for _, tag := range []string{"unique", "index"} {
+			if names := ft.Tag.Get(tag); names != "" {
+				for _, name := range strings.Split(names, ",") {
+					addIndex(strings.TrimSpace(name), tag == "unique", f.column)
+				}
+			}
+		}
+	}
+	return ts, nil
+}
+
+// Load the schema of the table from information_schema, nil if the table doesn't exist
+func loadTableSchema(tdx Tdx, table string) (*tableSchema, error) {
+	rows, err := tdx.Query("SELECT COLUMN_NAME, COLUMN_TYPE, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA "+
+		"FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ts := &tableSchema{name: table, columns: make([]*columnSchema, 0)}
+	for rows.Next() {
+		cs := &columnSchema{}
+		var nullable, extra string
+		if err := rows.Scan(&cs.name, &cs.typ, &cs.dataType, &nullable, &cs.dflt, &extra); err != nil {
+			return nil, err
+		}
+		// tinyint(1) is kept as bool
+		if cs.typ = strings.ToLower(cs.typ); !strings.HasPrefix(cs.typ, "tinyint(1)") {
+			cs.typ = displayWidthReg.ReplaceAllString(cs.typ, "$1")
+		}
+		cs.dataType = strings.ToLower(cs.dataType)
+		cs.nullable = nullable == "YES"
+		extra = strings.ToLower(extra)
+		cs.ai = strings.Contains(extra, "auto_increment")
+		if i := strings.Index(extra, "on update "); i >= 0 {
+			cs.onUpdate = strings.ToUpper(strings.TrimSuffix(extra[i+len("on update "):], "()"))
+		}
+		ts.columns = append(ts.columns, cs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ts.columns) == 0 {
+		return nil, nil
+	}
+
+	rows, err = tdx.Query("SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM information_schema.STATISTICS "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY INDEX_NAME, SEQ_IN_INDEX", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byName := map[string]*indexSchema{}
+	for rows.Next() {
+		var name string
+		var column sql.NullString
+		var nonUnique int
+		if err := rows.Scan(&name, &nonUnique, &column); err != nil {
+			return nil, err
+		}
+		idx := byName[name]
+		if idx == nil {
+			idx = &indexSchema{name: name, unique: nonUnique == 0}
+			byName[name] = idx
+			ts.indexes = append(ts.indexes, idx)
+		}
+		// the functional key part has no column
+		idx.columns = append(idx.columns, column.String)
+	}
+	return ts, rows.Err()
+}
+
+// Whether the type of db column matches the model column, the integer types are matched regardless of size,
+// as well as the string types, since the size is not known by the field
+func (cs *columnSchema) typeMatches(db *columnSchema) bool {
+	if cs.typ == db.typ || cs.exact {
+		return cs.typ == db.typ
+	}
+	if strings.Contains(cs.typ, " unsigned") != strings.Contains(db.typ, " unsigned") {
+		return false
+	}
+	for _, family := range typeFamilies {
+		if family[cs.dataType] && family[db.dataType] {
+			return true
+		}
+	}
+	return false
+}
+
+// The data types of the same family are matched, e.g. the string field could be mapped to text or decimal column
+var typeFamilies = []map[string]bool{
+	{"tinyint": true, "smallint": true, "mediumint": true, "int": true, "integer": true, "bigint": true,
+		"year": true, "bit": true},
+	{"float": true, "double": true, "real": true, "decimal": true, "numeric": true},
+	{"char": true, "varchar": true, "tinytext": true, "text": true, "mediumtext": true, "longtext": true,
+		"enum": true, "set": true, "time": true, "decimal": true, "numeric": true, "json": true},
+	{"binary": true, "varbinary": true, "tinyblob": true, "blob": true, "mediumblob": true, "longblob": true},
+	{"date": true, "datetime": true, "timestamp": true},
+}
+
+// Whether the default of db column matches the model column, the db column shouldn't have default if the model
+// column doesn't, except the auto timestamp, i.e. the time field of column with DEFAULT CURRENT_TIMESTAMP
+func (cs *columnSchema) defaultMatches(db *columnSchema) bool {
+	if !cs.dflt.Valid {
+		return !db.dflt.Valid || ((cs.dataType == "datetime" || cs.dataType == "timestamp") &&
+			strings.HasPrefix(strings.ToUpper(db.dflt.String), "CURRENT_TIMESTAMP"))
+	}
+	if !db.dflt.Valid || cs.onUpdate != db.onUpdate {
+		return false
+	}
+	// the numbers are compared by value, e.g. 0 and 0.00 of decimal
+	n1, err1 := strconv.ParseFloat(cs.dflt.String, 64)
+	n2, err2 := strconv.ParseFloat(db.dflt.String, 64)
+	if err1 == nil && err2 == nil {
+		return n1 == n2
+	}
+	normalize := func(s string) string {
+		return strings.TrimSuffix(strings.ToUpper(s), "()")
+	}
+	return normalize(cs.dflt.String) == normalize(db.dflt.String)
+}
+
+// The column definition of CREATE or ALTER TABLE
+func (cs *columnSchema) definition() string {
+	def := "`" + cs.name + "` " + cs.typ
+	if cs.nullable {
+		def += " NULL"
+	} else {
+		def += " NOT NULL"
+	}
+	if cs.dflt.Valid {
+		if strings.HasPrefix(strings.ToUpper(cs.dflt.String), "CURRENT_TIMESTAMP") {
+			def += " DEFAULT " + cs.dflt.String
+		} else {
+			def += " DEFAULT '" + strings.Replace(cs.dflt.String, "'", "''", -1) + "'"
+		}
+	}
+	if cs.ai {
+		def += " AUTO_INCREMENT"
+	}
+	if cs.onUpdate != "" {
+		def += " ON UPDATE " + cs.onUpdate
+	}
+	return def
+}
+
+// The index definition of CREATE or ALTER TABLE
+func (idx *indexSchema) definition() string {
+	cols := "`" + strings.Join(idx.columns, "`, `") + "`"
+	switch {
+	case idx.name == "PRIMARY":
+		return "PRIMARY KEY (" + cols + ")"
+	case idx.unique:
+		return "UNIQUE KEY `" + idx.name + "` (" + cols + ")"
+	}
+	return "KEY `" + idx.name + "` (" + cols + ")"
+}
+
+func (idx *indexSchema) dropClause() string {
+	if idx.name == "PRIMARY" {
+		return "DROP PRIMARY KEY"
+	}
+	return "DROP INDEX `" + idx.name + "`"
+}
+
+// DiffKind is the change of a column or an index to converge the database to the model
+type DiffKind string
+
+const (
+	DiffAdd    DiffKind = "add"
+	DiffDrop   DiffKind = "drop"
+	DiffModify DiffKind = "modify"
+)
+
+// ColumnDiff is a mismatched column, Model and Database are the column definitions, e.g. `name` varchar(255) NOT NULL,
+// Model is empty if the column should be dropped, and Database is empty if it should be added
+type ColumnDiff struct {
+	Kind     DiffKind
+	Column   string
+	Model    string
+	Database string
+}
+
+// IndexDiff is a mismatched index, Model and Database are the index definitions, e.g. UNIQUE KEY `uk_name` (`name`)
+type IndexDiff struct {
+	Kind     DiffKind
+	Index    string
+	Model    string
+	Database string
+}
+
+// TableDiff is the difference between the model and its table in database, see ORM.DiffTables
+type TableDiff struct {
+	Table string
+	// The table doesn't exist in database
+	Missing bool
+	Columns []ColumnDiff
+	Indexes []IndexDiff
+	model   *tableSchema
+	// the clauses of ALTER TABLE
+	clauses []string
+}
+
+// Whether the table matches the model
+func (d *TableDiff) Empty() bool {
+	return !d.Missing && len(d.Columns) == 0 && len(d.Indexes) == 0
+}
+
+func (d *TableDiff) String() string {
+	if d.Missing {
+		return "table " + d.Table + " is missing"
+	}
+	lines := make([]string, 0, len(d.Columns)+len(d.Indexes))
+	for _, c := range d.Columns {
+		lines = append(lines, fmt.Sprintf("%s column %s: %s -> %s", c.Kind, c.Column, c.Database, c.Model))
+	}
+	for _, idx := range d.Indexes {
+		lines = append(lines, fmt.Sprintf("%s index %s: %s -> %s", idx.Kind, idx.Index, idx.Database, idx.Model))
+	}
+	return "table " + d.Table + ":\n\t" + strings.Join(lines, "\n\t")
+}
+
+// The statements to converge the table to the model, CREATE TABLE if the table is missing, otherwise ALTER TABLE.
+// The dropped columns and indexes are included, review them before executing
+func (d *TableDiff) Statements() []string {
+	if d.Missing {
+		return []string{createTableSQL(d.model, false)}
+	}
+	if len(d.clauses) == 0 {
+		return []string{}
+	}
+	return []string{"ALTER TABLE `" + d.Table + "`\n  " + strings.Join(d.clauses, ",\n  ")}
+}
+
+func createTableSQL(ts *tableSchema, ifNotExists bool) string {
+	defs := make([]string, 0, len(ts.columns)+len(ts.indexes))
+	for _, cs := range ts.columns {
+		defs = append(defs, cs.definition())
+	}
+	for _, idx := range ts.indexes {
+		defs = append(defs, idx.definition())
+	}
+	create := "CREATE TABLE "
+	if ifNotExists {
+		create += "IF NOT EXISTS "
+	}
+	return create + "`" + ts.name + "` (\n  " + strings.Join(defs, ",\n  ") + "\n)"
+}
+
+// Compare the model schema with the table schema in database
+func diffTable(model *tableSchema, db *tableSchema) *TableDiff {
+	d := &TableDiff{Table: model.name, Columns: []ColumnDiff{}, Indexes: []IndexDiff{}, model: model}
+	if db == nil {
+		d.Missing = true
+		return d
+	}
+	dbColumns := make(map[string]*columnSchema, len(db.columns))
+	for _, cs := range db.columns {
+		dbColumns[cs.name] = cs
+	}
+	modelColumns := make(map[string]bool, len(model.columns))
+	for _, cs := range model.columns {
+		modelColumns[cs.name] = true
+	}
+	modelIndexes := make(map[string]*indexSchema, len(model.indexes))
+	for _, idx := range model.indexes {
+		modelIndexes[idx.name] = idx
+	}
+	dbIndexes := make(map[string]*indexSchema, len(db.indexes))
+	for _, idx := range db.indexes {
+		dbIndexes[idx.name] = idx
+	}
+
+	// the indexes are dropped before the columns, and added after them
+	addIndexes := make([]string, 0)
+	for _, idx := range db.indexes {
+		mIdx := modelIndexes[idx.name]
+		if mIdx == nil {
+			d.Indexes = append(d.Indexes, IndexDiff{Kind: DiffDrop, Index: idx.name, Database: idx.definition()})
+			d.clauses = append(d.clauses, idx.dropClause())
+		} else if mIdx.definition() != idx.definition() {
+			d.Indexes = append(d.Indexes, IndexDiff{Kind: DiffModify, Index: idx.name, Model: mIdx.definition(),
+				Database: idx.definition()})
+			d.clauses = append(d.clauses, idx.dropClause())
+			addIndexes = append(addIndexes, "ADD "+mIdx.definition())
+		}
+	}
+	for _, idx := range model.indexes {
+		if dbIndexes[idx.name] == nil {
+			d.Indexes = append(d.Indexes, IndexDiff{Kind: DiffAdd, Index: idx.name, Model: idx.definition()})
+			addIndexes = append(addIndexes, "ADD "+idx.definition())
+		}
+	}
+
+	for _, cs := range db.columns {
+		if !modelColumns[cs.name] {
+			d.Columns = append(d.Columns, ColumnDiff{Kind: DiffDrop, Column: cs.name, Database: cs.definition()})
+			d.clauses = append(d.clauses, "DROP COLUMN `"+cs.name+"`")
+		}
+	}
+	position := "FIRST"
+	for _, cs := range model.columns {
+		dbc := dbColumns[cs.name]
+		switch {
+		case dbc == nil:
+			d.Columns = append(d.Columns, ColumnDiff{Kind: DiffAdd, Column: cs.name, Model: cs.definition()})
+			d.clauses = append(d.clauses, "ADD COLUMN "+cs.definition()+" "+position)
+		case !cs.typeMatches(dbc) || (cs.nullable != dbc.nullable && !cs.anyNull) || cs.ai != dbc.ai ||
+			!cs.defaultMatches(dbc):
+			d.Columns = append(d.Columns, ColumnDiff{Kind: DiffModify, Column: cs.name, Model: cs.definition(),
+				Database: dbc.definition()})
+			d.clauses = append(d.clauses, "MODIFY COLUMN "+cs.definition())
+		}
+		position = "AFTER `" + cs.name + "`"
+	}
+	d.clauses = append(d.clauses, addIndexes...)
+	return d
+}
+
+func diffTableModel(tdx Tdx, s interface{}) (*TableDiff, error) {
+	if _, ok := dialectOf(tdx).(mysqlDialect); !ok {
+		return nil, fmt.Errorf("schema diff of %s %w", dialectOf(tdx).DriverName(), ErrUnsupportedType)
+	}
+	model, err := modelInfoOf(s).schema()
+	if err != nil {
+		return nil, err
+	}
+	db, err := loadTableSchema(tdx, model.name)
+	if err != nil {
+		return nil, err
+	}
+	return diffTable(model, db), nil
+}
+
+func diffTableModels(tdx Tdx, tables map[string]interface{}) ([]*TableDiff, error) {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ret := make([]*TableDiff, 0)
+	for _, name := range names {
+		d, err := diffTableModel(tdx, tables[name])
+		if err != nil {
+			return nil, err
+		}
+		if !d.Empty() {
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
+
+// Create the table of the model if it doesn't exist
+func createTable(tdx Tdx, s interface{}) error {
+	if _, ok := dialectOf(tdx).(mysqlDialect); !ok {
+		return fmt.Errorf("create table of %s %w", dialectOf(tdx).DriverName(), ErrUnsupportedType)
+	}
+	ts, err := modelInfoOf(s).schema()
+	if err != nil {
+		return err
+	}
+	_, err = tdx.Exec(createTableSQL(ts, true))
+	return err
+}
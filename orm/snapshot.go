@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"reflect"
+)
+
+var snapshotType = reflect.TypeOf(Snapshot{})
+
+// Snapshot records the column values of a model when it's loaded from db. Embed it into the model
+// struct to enable dirty tracking, then UpdateChanged only writes the columns changed since loaded, i.e.
+//
+//	type Article struct {
+//		orm.Snapshot
+//		ArticleId int64 `pk:"true" ai:"true"`
+//		Title     string
+//	}
+type Snapshot struct {
+	values map[string]interface{}
+}
+
+func (ss Snapshot) changedFields(v reflect.Value, mi *modelInfo) map[string]bool {
+	ret := make(map[string]bool)
+	for name, old := range ss.values {
+		f := mi.byName[name]
+		if !reflect.DeepEqual(old, snapshotValue(v.FieldByIndex(f.index), f)) {
+			ret[name] = true
+		}
+	}
+	return ret
+}
+
+// Index of the Snapshot field in the struct, -1 if it's not tracked
+func snapshotFieldIndex(t reflect.Type) int {
+	for k := 0; k < t.NumField(); k++ {
+		if ft := t.Field(k); ft.Type == snapshotType && ft.PkgPath == "" {
+			return k
+		}
+	}
+	return -1
+}
+
+func recordSnapshot(v reflect.Value, mi *modelInfo) {
+	if mi.snapshot < 0 {
+		return
+	}
+	values := make(map[string]interface{}, len(mi.fields))
+	for _, f := range mi.fields {
+		if f.pk || f.ignore {
+			continue
+		}
+		values[f.name] = snapshotValue(v.FieldByIndex(f.index), f)
+	}
+	v.Field(mi.snapshot).Set(reflect.ValueOf(Snapshot{values: values}))
+}
+
+// Record the values of the updated fields into the snapshot, all the fields if only is nil. The snapshot is
+// kept if it isn't recorded, so that UpdateChanged still writes all the fields
+func updateSnapshot(v reflect.Value, mi *modelInfo, only map[string]bool) {
+	if mi.snapshot < 0 {
+		return
+	}
+	if only == nil {
+		recordSnapshot(v, mi)
+		return
+	}
+	old := v.Field(mi.snapshot).Interface().(Snapshot).values
+	if old == nil {
+		return
+	}
+	// copy the values, which may be shared by the copies of the model
+	values := make(map[string]interface{}, len(old))
+	for name, value := range old {
+		values[name] = value
+	}
+	for name := range only {
+		if f := mi.byName[name]; f != nil && !f.pk && !f.ignore {
+			values[name] = snapshotValue(v.FieldByIndex(f.index), f)
+		}
+	}
+	v.Field(mi.snapshot).Set(reflect.ValueOf(Snapshot{values: values}))
+}
+
+// The value of the field to be compared, it's copied so that the in-place modification can be detected
+func snapshotValue(fv reflect.Value, f *fieldInfo) interface{} {
+	if f.conv != nil {
+		// the converted column value, i.e. json, is compared for the field of map, struct, etc.
+		value, err := f.conv.ToColumn(fv)
+		if err != nil {
+			return err
+		}
+		if b, ok := value.([]byte); ok {
+			value = append([]byte{}, b...)
+		}
+		return value
+	}
+	if fv.Kind() == reflect.Slice && !fv.IsNil() {
+		// copy the slice, i.e. []byte
+		cp := reflect.MakeSlice(fv.Type(), fv.Len(), fv.Len())
+		reflect.Copy(cp, fv)
+		return cp.Interface()
+	}
+	if fv.Kind() == reflect.Ptr && !fv.IsNil() {
+		// copy the pointed value of nullable field
+		cp := reflect.New(fv.Type().Elem())
+		cp.Elem().Set(fv.Elem())
+		return cp.Interface()
+	}
+	return fv.Interface()
+}
@@ -15,28 +15,113 @@ type column struct {
 	DataType      string
 	ColumnType    string
 	ColumnKey     string
+	IsNullable    string
 	Extra         string
 	ColumnComment string
 }
 
-func (col column) GetDataType() string {
-	kFieldTypes := map[string]string{
-		"bigint":    "int64",
-		"int":       "int",
-		"tinyint":   "int",
-		"smallint":  "int",
-		"char":      "string",
-		"varchar":   "string",
-		"blob":      "[]byte",
-		"date":      "time.Time",
-		"datetime":  "time.Time",
-		"timestamp": "time.Time",
-		"float":     "float64",
-		"decimal":   "float64",
-		"double":    "float64",
-		"bit":       "uint64",
+func (col column) Nullable() bool {
+	return strings.ToUpper(col.IsNullable) == "YES"
+}
+
+var sqlNullTypes = map[string]string{
+	"int64":     "sql.NullInt64",
+	"int":       "sql.NullInt64",
+	"string":    "sql.NullString",
+	"time.Time": "sql.NullTime",
+	"float64":   "sql.NullFloat64",
+	"bool":      "sql.NullBool",
+}
+
+// The Go type of nullable column, which is pointer of the data type, or sql.Null* if nullStyle is "sql".
+// The slice type, i.e. []byte and json.RawMessage, is kept since nil is NULL
+func nullableType(dataType string, nullStyle string) string {
+	if strings.HasPrefix(dataType, "[]") || dataType == "json.RawMessage" {
+		return dataType
+	}
+	if nullStyle == "sql" {
+		if t, ok := sqlNullTypes[dataType]; ok {
+			return t
+		}
+	}
+	return "*" + dataType
+}
+
+var kFieldTypes = map[string]string{
+	"bigint":             "int64",
+	"int":                "int",
+	"integer":            "int",
+	"mediumint":          "int",
+	"smallint":           "int",
+	"tinyint":            "int",
+	"year":               "int",
+	"char":               "string",
+	"varchar":            "string",
+	"tinytext":           "string",
+	"text":               "string",
+	"mediumtext":         "string",
+	"longtext":           "string",
+	"enum":               "string",
+	"set":                "string",
+	"time":               "string",
+	"binary":             "[]byte",
+	"varbinary":          "[]byte",
+	"tinyblob":           "[]byte",
+	"blob":               "[]byte",
+	"mediumblob":         "[]byte",
+	"longblob":           "[]byte",
+	"geometry":           "[]byte",
+	"point":              "[]byte",
+	"linestring":         "[]byte",
+	"polygon":            "[]byte",
+	"multipoint":         "[]byte",
+	"multilinestring":    "[]byte",
+	"multipolygon":       "[]byte",
+	"geometrycollection": "[]byte",
+	"date":               "time.Time",
+	"datetime":           "time.Time",
+	"timestamp":          "time.Time",
+	"float":              "float64",
+	"double":             "float64",
+	"real":               "float64",
+	"decimal":            "float64",
+	"numeric":            "float64",
+	"bit":                "uint64",
+	"json":               "json.RawMessage",
+}
+
+var kUnsignedFieldTypes = map[string]string{
+	"bigint":    "uint64",
+	"int":       "uint",
+	"integer":   "uint",
+	"mediumint": "uint",
+	"smallint":  "uint",
+	"tinyint":   "uint",
+}
+
+// The Go type of the column. The type map of config is looked up by the column type first, e.g. "bigint(20) unsigned",
+// and then by the data type, e.g. "bigint". The builtin mapping is used if it's not found
+func (col column) GetDataType(config codeConfig) string {
+	columnType := strings.ToLower(col.ColumnType)
+	dataType := strings.ToLower(col.DataType)
+	if fieldType, ok := config.typeMap[columnType]; ok {
+		return fieldType
+	}
+	if fieldType, ok := config.typeMap[dataType]; ok {
+		return fieldType
+	}
+	if columnType == "tinyint(1)" {
+		return "bool"
+	}
+	if strings.Contains(columnType, "unsigned") {
+		if fieldType, ok := kUnsignedFieldTypes[dataType]; ok {
+			return fieldType
+		}
+	}
+	if (dataType == "decimal" || dataType == "numeric") && config.decimalType != "" {
+		return config.decimalType
 	}
-	if fieldType, ok := kFieldTypes[strings.ToLower(col.DataType)]; !ok {
+	if fieldType, ok := kFieldTypes[dataType]; !ok {
 		return "string"
 	} else {
 		return fieldType
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGetDataType(t *testing.T) {
+	config := codeConfig{
+		typeMap: map[string]string{
+			"bigint(20) unsigned": "int64",
+			"json":                "map[string]interface{}",
+			"char(36)":            "uuid.UUID",
+		},
+	}
+	decimalConfig := codeConfig{decimalType: "decimal.Decimal"}
+	cases := []struct {
+		columnType string
+		dataType   string
+		config     codeConfig
+		goType     string
+	}{
+		{"bigint(20)", "bigint", codeConfig{}, "int64"},
+		{"bigint(20) unsigned", "bigint", codeConfig{}, "uint64"},
+		{"int(10) unsigned", "int", codeConfig{}, "uint"},
+		{"tinyint(1)", "tinyint", codeConfig{}, "bool"},
+		{"tinyint(1) unsigned", "tinyint", codeConfig{}, "uint"},
+		{"tinyint(4)", "tinyint", codeConfig{}, "int"},
+		{"decimal(20,4)", "decimal", codeConfig{}, "float64"},
+		{"decimal(20,4)", "decimal", decimalConfig, "decimal.Decimal"},
+		{"numeric(10,2)", "numeric", decimalConfig, "decimal.Decimal"},
+		{"json", "json", codeConfig{}, "json.RawMessage"},
+		{"varbinary(16)", "varbinary", codeConfig{}, "[]byte"},
+		{"DATETIME", "DATETIME", codeConfig{}, "time.Time"},
+		{"geography", "geography", codeConfig{}, "string"},
+		// the column type overrides the unsigned mapping, and the data type overrides the builtin one
+		{"bigint(20) unsigned", "bigint", config, "int64"},
+		{"bigint(11) unsigned", "bigint", config, "uint64"},
+		{"json", "json", config, "map[string]interface{}"},
+		{"char(36)", "char", config, "uuid.UUID"},
+		{"char(32)", "char", config, "string"},
+	}
+	for _, c := range cases {
+		col := column{ColumnType: c.columnType, DataType: c.dataType}
+		if goType := col.GetDataType(c.config); goType != c.goType {
+			t.Errorf("%s: expected %s, but got %s", c.columnType, c.goType, goType)
+		}
+	}
+}
+
+func TestNullableType(t *testing.T) {
+	cases := []struct {
+		dataType  string
+		nullStyle string
+		goType    string
+	}{
+		{"int64", "", "*int64"},
+		{"int64", "sql", "sql.NullInt64"},
+		{"time.Time", "", "*time.Time"},
+		{"time.Time", "sql", "sql.NullTime"},
+		{"bool", "sql", "sql.NullBool"},
+		{"uint64", "sql", "*uint64"},
+		{"decimal.Decimal", "sql", "*decimal.Decimal"},
+		{"[]byte", "", "[]byte"},
+		{"[]byte", "sql", "[]byte"},
+		{"json.RawMessage", "", "json.RawMessage"},
+	}
+	for _, c := range cases {
+		if goType := nullableType(c.dataType, c.nullStyle); goType != c.goType {
+			t.Errorf("%s of %q: expected %s, but got %s", c.dataType, c.nullStyle, c.goType, goType)
+		}
+	}
+}